@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+	"plarix-action/internal/pricing"
+)
+
+// Tolerances when comparing a provider-reported cost with our own figure.
+// Providers round differently, so only flag disagreements beyond both.
+const (
+	mismatchRelTolerance = 0.01                             // 1%
+	mismatchAbsTolerance = money.USD(money.Scale / 1000000) // $0.000001
+)
+
+// applyCost stamps the entry and prices it with the rates in effect at that
+// timestamp, for its batch or service tier. Entries without usage
+// (CostKnown == false) are left untouched, and entries whose timestamp does
+// not parse are only priced by a provider-reported cost.
+//
+// A provider-reported cost (ReportedCostUSD) wins over the pricing table;
+// if the table also knows the model and disagrees, the entry is flagged
+// with CostMismatch and the computed figure is kept alongside.
+func applyCost(prices *pricing.Prices, e *ledger.Entry) {
+	if e.Timestamp == "" {
+		e.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	if !e.CostKnown {
+		return
+	}
+
+	var result pricing.CostResult
+	if e.Model != "" {
+		at, err := time.Parse(time.RFC3339, e.Timestamp)
+		if err != nil {
+			// Pricing as of the zero time would pick the wrong period.
+			result.UnknownReason = fmt.Sprintf("invalid timestamp %q", e.Timestamp)
+		} else {
+			result = prices.ComputeCostFor(pricing.Call{
+				Model:        e.Model,
+				InputTokens:  e.InputTokens,
+				OutputTokens: e.OutputTokens,
+				At:           at,
+				ServiceTier:  e.ServiceTier,
+				Batch:        e.Batch,
+			})
+		}
+	}
+
+	if e.ReportedCostUSD != nil {
+		e.CostUSD = *e.ReportedCostUSD
+		e.CostSource = ledger.CostSourceProvider
+		if result.Known && !costsMatch(*e.ReportedCostUSD, result.CostUSD) {
+			e.CostMismatch = true
+			e.ComputedCostUSD = result.CostUSD
+		}
+		return
+	}
+
+	if e.Model == "" {
+		return
+	}
+	if result.Known {
+		e.CostUSD = result.CostUSD
+		e.CostSource = ledger.CostSourcePricingTable
+		e.PricingTier = result.Tier
+	} else {
+		e.CostKnown = false
+		e.UnknownReason = result.UnknownReason
+	}
+}
+
+// repriceEntry discards a recorded cost and prices the entry again.
+// Entries that never had usage (no tokens and unknown cost) stay unknown,
+// since the pricing table cannot help them.
+func repriceEntry(prices *pricing.Prices, e *ledger.Entry) {
+	if !e.CostKnown && e.InputTokens == 0 && e.OutputTokens == 0 && e.ReportedCostUSD == nil {
+		return
+	}
+	e.CostUSD = 0
+	e.CostSource = ""
+	e.PricingTier = ""
+	e.CostMismatch = false
+	e.ComputedCostUSD = 0
+	e.CostKnown = true
+	e.UnknownReason = ""
+	applyCost(prices, e)
+}
+
+// costsMatch reports whether two USD amounts agree within tolerance.
+func costsMatch(reported, computed money.USD) bool {
+	diff := (reported - computed).Abs()
+	if diff <= mismatchAbsTolerance {
+		return true
+	}
+	larger := reported.Abs()
+	if computed.Abs() > larger {
+		larger = computed.Abs()
+	}
+	return float64(diff) <= mismatchRelTolerance*float64(larger)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+	"plarix-action/internal/pricing"
+)
+
+func usd(s string) *money.USD {
+	a, err := money.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return &a
+}
+
+func TestApplyCost(t *testing.T) {
+	prices, err := pricing.Parse([]byte(`{"as_of":"2026-01-01","models":{
+		"dollar": {"input_per_1k": 1, "output_per_1k": 0},
+		"tiny":   {"input_per_1k": 0.000001, "output_per_1k": 0}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		model        string
+		reported     *money.USD
+		wantCost     string
+		wantSource   string
+		wantMismatch bool
+		wantComputed string
+	}{
+		{"computed only", "dollar", nil, "1", ledger.CostSourcePricingTable, false, "0"},
+		{"reported only", "", usd("0.25"), "0.25", ledger.CostSourceProvider, false, "0"},
+		{"within 1%", "dollar", usd("1.009"), "1.009", ledger.CostSourceProvider, false, "0"},
+		{"within $0.000001", "tiny", usd("0.0000009"), "0.0000009", ledger.CostSourceProvider, false, "0"},
+		{"disagree", "dollar", usd("1.5"), "1.5", ledger.CostSourceProvider, true, "1"},
+		{"unknown model", "mystery", usd("0.3"), "0.3", ledger.CostSourceProvider, false, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ledger.Entry{
+				Model:           tt.model,
+				InputTokens:     1000,
+				CostKnown:       true,
+				ReportedCostUSD: tt.reported,
+				Timestamp:       "2026-03-01T00:00:00Z",
+			}
+			applyCost(prices, &e)
+			if !e.CostKnown {
+				t.Fatalf("CostKnown = false (%s), want true", e.UnknownReason)
+			}
+			if e.CostUSD.String() != tt.wantCost || e.CostSource != tt.wantSource {
+				t.Errorf("cost = %s from %q, want %s from %q", e.CostUSD, e.CostSource, tt.wantCost, tt.wantSource)
+			}
+			if e.CostMismatch != tt.wantMismatch || e.ComputedCostUSD.String() != tt.wantComputed {
+				t.Errorf("mismatch = %v (computed %s), want %v (computed %s)", e.CostMismatch, e.ComputedCostUSD, tt.wantMismatch, tt.wantComputed)
+			}
+		})
+	}
+}
+
+func TestApplyCostUnknownModel(t *testing.T) {
+	prices, err := pricing.Parse([]byte(`{"as_of":"2026-01-01","models":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ledger.Entry{Model: "mystery", InputTokens: 10, CostKnown: true}
+	applyCost(prices, &e)
+	if e.CostKnown || e.UnknownReason == "" || e.Timestamp == "" {
+		t.Errorf("entry = %+v, want an unknown, stamped cost with a reason", e)
+	}
+}
+
+func TestCostsMatch(t *testing.T) {
+	tests := []struct {
+		reported, computed string
+		want               bool
+	}{
+		{"1", "1", true},
+		{"1.01", "1", true},
+		{"1", "1.01", true},
+		{"1.02", "1", false},
+		{"0.000001", "0", true},
+		{"0.0000011", "0", false},
+		{"-1", "1", false},
+	}
+	for _, tt := range tests {
+		if got := costsMatch(*usd(tt.reported), *usd(tt.computed)); got != tt.want {
+			t.Errorf("costsMatch(%s, %s) = %v, want %v", tt.reported, tt.computed, got, tt.want)
+		}
+	}
+}
+
+func TestRepriceEntry(t *testing.T) {
+	prices, err := pricing.Parse([]byte(`{"as_of":"2026-01-01","models":{
+		"m": {"input_per_1k": 1, "output_per_1k": 0, "effective_from": "2025-06-01",
+			"history": [{"input_per_1k": 3, "output_per_1k": 0, "effective_to": "2025-06-01"}]}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		entry      ledger.Entry
+		wantKnown  bool
+		wantCost   string
+		wantReason string
+	}{
+		{"before cut-over", ledger.Entry{Model: "m", InputTokens: 1000, CostKnown: true, CostUSD: *usd("9"), Timestamp: "2025-05-31T23:59:59Z"}, true, "3", ""},
+		{"after cut-over", ledger.Entry{Model: "m", InputTokens: 1000, CostKnown: true, CostUSD: *usd("9"), Timestamp: "2025-06-01T00:00:00Z"}, true, "1", ""},
+		{"was unknown", ledger.Entry{Model: "m", InputTokens: 2000, UnknownReason: "model \"m\" not in pricing table", Timestamp: "2025-07-01T00:00:00Z"}, true, "2", ""},
+		{"no usage", ledger.Entry{Model: "m", UnknownReason: "no usage field in response", Timestamp: "2025-07-01T00:00:00Z"}, false, "0", "no usage field in response"},
+		{"bad timestamp", ledger.Entry{Model: "m", InputTokens: 1000, CostKnown: true, CostUSD: *usd("1"), Timestamp: "yesterday"}, false, "0", `invalid timestamp "yesterday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.entry
+			repriceEntry(prices, &e)
+			if e.CostKnown != tt.wantKnown || e.CostUSD.String() != tt.wantCost || e.UnknownReason != tt.wantReason {
+				t.Errorf("repriced = known %v, %s, %q; want known %v, %s, %q", e.CostKnown, e.CostUSD, e.UnknownReason, tt.wantKnown, tt.wantCost, tt.wantReason)
+			}
+		})
+	}
+}
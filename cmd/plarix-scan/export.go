@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"plarix-action/internal/export"
+	"plarix-action/internal/ledger"
+)
+
+// runExport writes a ledger and its rotated segments as CSV or Parquet.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+
+	ledgerPath := fs.String("ledger", "plarix-ledger.jsonl", "Path to ledger file")
+	format := fs.String("format", "csv", "Output format: csv, parquet")
+	outPath := fs.String("out", "", "Output file (default: stdout)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, *export.Table) error
+	switch *format {
+	case "csv":
+		write = export.WriteCSV
+	case "parquet":
+		write = export.WriteParquet
+	default:
+		return fmt.Errorf("unknown export format %q (want csv or parquet)", *format)
+	}
+
+	var entries []ledger.Entry
+	torn, err := ledger.ScanAll(*ledgerPath, func(e ledger.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read ledger: %w", err)
+	}
+	for _, t := range torn {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", t)
+	}
+
+	out := os.Stdout
+	if *outPath != "" {
+		if out, err = os.Create(*outPath); err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer out.Close()
+	}
+
+	bw := bufio.NewWriter(out)
+	if err := write(bw, export.Flatten(entries)); err != nil {
+		return fmt.Errorf("write %s: %w", *format, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write %s: %w", *format, err)
+	}
+	if *outPath != "" {
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("write %s: %w", *format, err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d entries to %s\n", len(entries), *outPath)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"plarix-action/internal/ledger"
+)
+
+// runLedger dispatches the `ledger` subcommands.
+func runLedger(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: plarix-scan ledger verify|migrate [--ledger <path>]")
+	}
+
+	switch args[0] {
+	case "verify":
+		return runLedgerVerify(args[1:])
+	case "migrate":
+		return runLedgerMigrate(args[1:])
+	default:
+		return fmt.Errorf("unknown ledger command: %s", args[0])
+	}
+}
+
+// runLedgerVerify checks the hash chain of a ledger and its rotated segments
+// and reports the first broken link.
+func runLedgerVerify(args []string) error {
+	fs := flag.NewFlagSet("ledger verify", flag.ExitOnError)
+
+	ledgerPath := fs.String("ledger", "plarix-ledger.jsonl", "Path to ledger file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := ledger.Verify(*ledgerPath, hmacKey())
+	if err != nil {
+		return fmt.Errorf("verify ledger: %w", err)
+	}
+
+	for _, t := range r.Torn {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", t)
+	}
+	if r.Break != nil {
+		return fmt.Errorf("hash chain broken at %s", r.Break)
+	}
+	if r.Chained == 0 {
+		return fmt.Errorf("%s is not hash-chained (%d entries)", *ledgerPath, r.Entries)
+	}
+
+	fmt.Printf("OK: %d entries verified (%s)\n", r.Chained, r.Algorithm)
+	if unchained := r.Entries - r.Chained; unchained > 0 {
+		fmt.Printf("Note: %d earlier entries predate the hash chain and were not verified\n", unchained)
+	}
+	if r.Pruned {
+		fmt.Println("Note: the chain starts after entries that are no longer present (e.g. segments removed by retention)")
+	}
+	return nil
+}
+
+// runLedgerMigrate rewrites a ledger and its rotated segments in the current
+// schema. A hash-chained ledger is verified first and re-chained afterwards,
+// so migration cannot hide an earlier edit.
+func runLedgerMigrate(args []string) error {
+	fs := flag.NewFlagSet("ledger migrate", flag.ExitOnError)
+
+	ledgerPath := fs.String("ledger", "plarix-ledger.jsonl", "Path to ledger file")
+	outPath := fs.String("out", "", "Write the migrated ledger to this single file instead of in place")
+	hashChain := fs.Bool("hash-chain", false, "Hash-chain the migrated ledger even if it was not chained")
+	force := fs.Bool("force", false, "Migrate even if the existing hash chain is broken")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *outPath != "" && filepath.Clean(*outPath) == filepath.Clean(*ledgerPath) {
+		return fmt.Errorf("--out must differ from --ledger (omit it to migrate in place)")
+	}
+
+	key := hmacKey()
+	v, err := ledger.Verify(*ledgerPath, key)
+	if err != nil {
+		return fmt.Errorf("verify ledger: %w", err)
+	}
+	if v.Break != nil && !*force {
+		return fmt.Errorf("hash chain broken at %s; re-run with --force to migrate anyway", v.Break)
+	}
+
+	r, err := ledger.Migrate(*ledgerPath, ledger.MigrateOptions{
+		Out:     *outPath,
+		Chain:   v.Chained > 0 || *hashChain,
+		HMACKey: key,
+	})
+	for _, t := range r.Torn {
+		fmt.Fprintf(os.Stderr, "Warning: %s; not migrated\n", t)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate ledger: %w", err)
+	}
+
+	fmt.Printf("Migrated %d entries in %d files to schema version %d (%d upgraded)\n",
+		r.Entries, r.Files, ledger.SchemaVersion, r.Upgraded)
+	return nil
+}
+
+// hmacKey returns the ledger HMAC key from the environment, if set.
+func hmacKey() []byte {
+	if key := os.Getenv(ledger.HMACKeyEnv); key != "" {
+		return []byte(key)
+	}
+	return nil
+}
@@ -6,20 +6,24 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"plarix-action/internal/action"
+	"plarix-action/internal/budget"
+	"plarix-action/internal/currency"
 	"plarix-action/internal/ledger"
 	"plarix-action/internal/pricing"
 	"plarix-action/internal/proxy"
+	"plarix-action/internal/report"
 )
 
 const version = "0.6.0"
@@ -41,6 +45,28 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "report":
+		if err := runReport(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "pricing":
+		if err := runPricing(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "ledger":
+		if err := runLedger(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "export":
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "schema":
+		os.Stdout.Write(ledger.Schema)
 	case "version", "--version", "-v":
 		fmt.Printf("plarix-scan v%s\n", version)
 	case "help", "--help", "-h":
@@ -58,22 +84,111 @@ func printUsage() {
 Commands:
   run       Run a command with LLM API cost tracking
   proxy     Start the proxy server in daemon mode
+  report    Summarize an existing ledger file
+  pricing   Maintain the pricing table (pricing sync --from <url|file>)
+  ledger    Check or upgrade a ledger (ledger verify, ledger migrate)
+  schema    Print the JSON Schema of a ledger line
+  export    Export a ledger as CSV or Parquet
   version   Print version information
   help      Show this help message
 
 Run Options:
   --command <string>   Command to execute (required)
-  --pricing <path>     Path to custom pricing JSON
-  --fail-on-cost <float>   Exit non-zero if cost exceeds threshold (USD)
+  --pricing <path>     Path to custom pricing JSON (default: prices/prices.json
+                       if present, else the bundled table)
+  --pricing-overlay <path>   Pricing JSON whose models add to/override the table (repeatable)
+  --fail-on-cost <float>   Exit non-zero if cost exceeds threshold (USD, see --budget-currency)
+  --model-cap <model=amount>   Exit non-zero if a model's cost exceeds its cap (repeatable)
+  --unknown-cost <policy>   Unknown-cost calls allowed: allow, fail, a number or a percentage
+                       such as 5% (default: allow)
+  --junit <path>       Write each budget rule as a JUnit XML test case
+  --currency <code>    Display costs in this currency (e.g. EUR)
+  --fx-rates <path>    Exchange-rate JSON used with --currency
+  --budget-currency <code>   Currency of --fail-on-cost and --model-cap amounts: USD
+                       (default) or the --currency code, converted at its rate
   --providers <csv>    Providers to intercept (default: openai,anthropic,openrouter)
-  --comment <mode>     Comment mode: pr, summary, both (default: both)
+  --tag <key=value>    Tag recorded on every call (repeatable or comma-separated)
+  --comment <mode>     Comment mode: pr, summary, both (default: both); on GitLab CI and
+                       Bitbucket Pipelines, pr is a merge request note or pull request comment
+                       (needs $GITLAB_TOKEN or $BITBUCKET_TOKEN) and summary is plarix-report.md
+  --check-run          Also create a "Plarix cost" check run on the PR, failing when a
+                       budget rule fails (needs the checks: write permission)
+  --commit-status      Also set a "Plarix cost" commit status, on PRs and on pushes
+                       (needs the statuses: write permission)
+  --badge <path>       Write an SVG badge with the cost per run (e.g. "LLM cost | $0.42/run")
+  --webhook-url <url>  POST the summary JSON and markdown report to this URL (any CI);
+                       signed with $PLARIX_WEBHOOK_SECRET if set
+  --bucket <width>     Add a time series to the summary: minute, hour, day or a duration
+  --report-template <path>   text/template file for the report (default: built-in layout)
+  --baseline <path>    plarix-summary.json of a previous run; the report shows the change
   --enable-openai-stream-usage-injection <bool>   Opt-in for OpenAI stream usage (default: false)
 
 Proxy Options:
   --port <int>         Port to listen on (default: 8080)
-  --pricing <path>     Path to custom pricing JSON
+  --pricing <path>     Path to custom pricing JSON (default: prices/prices.json
+                       if present, else the bundled table)
+  --pricing-overlay <path>   Pricing JSON whose models add to/override the table (repeatable)
   --ledger <path>      Path to ledger file (default: plarix-ledger.jsonl)
-  --providers <csv>    Providers to intercept (default: openai,anthropic,openrouter)`)
+  --providers <csv>    Providers to intercept (default: openai,anthropic,openrouter)
+  --tag <key=value>    Tag recorded on every call (repeatable or comma-separated);
+                       clients add per-call tags with the X-Plarix-Tags header
+  --rotate-size <size>       Rotate the ledger when it reaches this size (e.g. 100MB)
+  --rotate-interval <when>   Rotate the ledger hourly, daily, or every duration (e.g. 6h)
+  --compress-rotated   Gzip rotated ledger segments
+  --retain-age <age>   Delete rotated segments older than this (e.g. 30d, 720h)
+  --retain-segments <n>      Keep at most this many rotated segments
+  --fsync <policy>     When to fsync the ledger: always, interval, never (default: interval)
+  --fsync-interval <duration>   fsync period with --fsync interval (default: 1s)
+  --hash-chain         Chain entries by hash (HMAC with $PLARIX_LEDGER_HMAC_KEY if set)
+  --store <path>       Also record entries in an indexed store and serve the query
+                       API under /_plarix/ (entries, summary); loopback clients
+                       only, or any client with "Authorization: Bearer
+                       $PLARIX_API_TOKEN" if set
+
+Report Options:
+  --ledger <path>      Path to ledger file, read with its rotated segments (default: plarix-ledger.jsonl)
+  --pricing <path>     Path to custom pricing JSON (default: prices/prices.json
+                       if present, else the bundled table)
+  --pricing-overlay <path>   Pricing JSON whose models add to/override the table (repeatable)
+  --reprice            Recompute costs with the prices in effect at each entry's timestamp
+  --currency <code>    Display costs in this currency (e.g. EUR)
+  --fx-rates <path>    Exchange-rate JSON used with --currency
+  --store <path>       Read an indexed store (proxy --store) instead of --ledger
+  --since <time>       Only entries at or after this time (RFC 3339 or YYYY-MM-DD)
+  --until <time>       Only entries before this time (RFC 3339 or YYYY-MM-DD)
+  --provider <name>    Only entries from this provider
+  --model <name>       Only entries for this model
+  --tag <key=value>    Only entries with this tag (repeatable)
+  --bucket <width>     Add spend over time: minute, hour, day or a duration
+  --format <name>      markdown, json (the summary, as in plarix-summary.json) or html
+                       (a self-contained page with charts; hourly buckets unless --bucket) (default: markdown)
+  --out <path>         Write the report to this file (default: stdout)
+  --report-template <path>   text/template file for the markdown report (default: built-in layout)
+  --baseline <path>    plarix-summary.json of a previous run; the markdown report shows the change
+
+Pricing Sync Options:
+  --from <url|file>    Price feed: OpenRouter models listing or LiteLLM cost map (required)
+  --format <name>      Feed format: auto, openrouter, litellm (default: auto)
+  --pricing <path>     Pricing file to update (default: prices/prices.json)
+  --out <path>         Where to write the updated table (default: --pricing)
+  --add-new            Also add models that are not in the table yet
+  --dry-run            Show the diff without writing
+  --as-of <date>       Date of the snapshot, YYYY-MM-DD (default: today)
+
+Ledger Verify Options:
+  --ledger <path>      Path to ledger file, checked with its rotated segments (default: plarix-ledger.jsonl)
+                       HMAC-chained ledgers need $PLARIX_LEDGER_HMAC_KEY
+
+Ledger Migrate Options:
+  --ledger <path>      Ledger to upgrade in place, with its rotated segments (default: plarix-ledger.jsonl)
+  --out <path>         Write the migrated ledger to this single file instead
+  --hash-chain         Hash-chain the result even if the ledger was not chained
+  --force              Migrate even if the existing hash chain is broken
+
+Export Options:
+  --ledger <path>      Path to ledger file, read with its rotated segments (default: plarix-ledger.jsonl)
+  --format <name>      csv or parquet (default: csv)
+  --out <path>         Output file (default: stdout)`)
 }
 
 func runCmd(args []string) error {
@@ -81,15 +196,37 @@ func runCmd(args []string) error {
 
 	command := fs.String("command", "", "Command to execute (required)")
 	pricingPath := fs.String("pricing", "", "Path to custom pricing JSON")
+	var pricingOverlays stringList
+	fs.Var(&pricingOverlays, "pricing-overlay", "Pricing JSON layered over the table (repeatable)")
 	failOnCost := fs.Float64("fail-on-cost", 0, "Exit non-zero if cost exceeds threshold (USD)")
+	var modelCapFlags stringList
+	fs.Var(&modelCapFlags, "model-cap", "Exit non-zero if a model's cost exceeds this, model=amount (repeatable)")
+	unknownCost := fs.String("unknown-cost", "allow", "Unknown-cost policy: allow, fail, a number of calls or a percentage")
+	junitPath := fs.String("junit", "", "Write the budget checks as JUnit XML to this file")
+	currencyCode := fs.String("currency", "", "Display costs in this currency (e.g. EUR)")
+	fxRatesPath := fs.String("fx-rates", "", "Exchange-rate JSON used with --currency")
+	budgetCurrency := fs.String("budget-currency", "USD", "Currency of budget amounts: USD or the --currency code")
 	providers := fs.String("providers", "openai,anthropic,openrouter", "Providers to intercept")
+	var tagFlags stringList
+	fs.Var(&tagFlags, "tag", "Tag recorded on every call, key=value (repeatable)")
 	commentMode := fs.String("comment", "both", "Comment mode: pr, summary, both")
+	checkRun := fs.Bool("check-run", false, "Also report as a \""+action.CheckName+"\" check run on the PR")
+	commitStatus := fs.Bool("commit-status", false, "Also set a \""+action.CheckName+"\" commit status, also outside PRs")
+	badgePath := fs.String("badge", "", "Write an SVG cost badge to this file")
+	webhookURL := fs.String("webhook-url", "", "POST the summary JSON and markdown report to this URL")
+	bucket := fs.String("bucket", "", "Add a time series to the summary: minute, hour, day or a duration")
+	templatePath := fs.String("report-template", "", "text/template file for the report (default: built-in layout)")
+	baselinePath := fs.String("baseline", "", "plarix-summary.json of a previous run to compare costs with")
 	_ = fs.Bool("enable-openai-stream-usage-injection", false, "Opt-in for OpenAI stream usage")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
+	if *webhookURL != "" && !strings.HasPrefix(*webhookURL, "http://") && !strings.HasPrefix(*webhookURL, "https://") {
+		return fmt.Errorf("--webhook-url must be an http(s) URL")
+	}
+
 	// Get command from flag or env
 	if *command == "" {
 		if envCmd := os.Getenv("INPUT_COMMAND"); envCmd != "" {
@@ -100,13 +237,52 @@ func runCmd(args []string) error {
 	}
 
 	// Load pricing
-	prices, err := loadPricing(*pricingPath)
+	prices, err := loadPricing(*pricingPath, pricingOverlays)
 	if err != nil {
 		return fmt.Errorf("load pricing: %w", err)
 	}
 
+	rates, err := loadRates(*currencyCode, *fxRatesPath)
+	if err != nil {
+		return fmt.Errorf("load exchange rates: %w", err)
+	}
+
+	tags, err := ledger.ParseTags(strings.Join(tagFlags, ","))
+	if err != nil {
+		return err
+	}
+
+	bucketWidth, err := ledger.ParseBucketWidth(*bucket)
+	if err != nil {
+		return err
+	}
+
+	rules := budget.Rules{MaxCost: *failOnCost}
+	if !strings.EqualFold(*budgetCurrency, "USD") {
+		if rates == nil || !strings.EqualFold(*budgetCurrency, *currencyCode) {
+			return fmt.Errorf("--budget-currency must be USD or the --currency code")
+		}
+		rules.InDisplayCurrency = true
+	}
+	if rules.ModelCaps, err = budget.ParseModelCaps(strings.Join(modelCapFlags, ",")); err != nil {
+		return err
+	}
+	if rules.UnknownCost, err = budget.ParseUnknownPolicy(*unknownCost); err != nil {
+		return err
+	}
+
+	tmpl, err := loadReportTemplate(*templatePath)
+	if err != nil {
+		return err
+	}
+	baseline, err := loadBaseline(*baselinePath)
+	if err != nil {
+		return err
+	}
+
 	// Create aggregator and writer
 	agg := ledger.NewAggregator()
+	agg.SetBucketWidth(bucketWidth)
 	writer, err := ledger.NewWriter("plarix-ledger.jsonl")
 	if err != nil {
 		return fmt.Errorf("create ledger writer: %w", err)
@@ -116,17 +292,9 @@ func runCmd(args []string) error {
 	// Start proxy
 	proxyConfig := proxy.Config{
 		Providers: strings.Split(*providers, ","),
+		Tags:      tags,
 		OnEntry: func(e ledger.Entry) {
-			// Compute cost
-			if e.CostKnown && e.Model != "" {
-				result := prices.ComputeCost(e.Model, e.InputTokens, e.OutputTokens)
-				if result.Known {
-					e.CostUSD = result.CostUSD
-				} else {
-					e.CostKnown = false
-					e.UnknownReason = result.UnknownReason
-				}
-			}
+			applyCost(prices, &e)
 
 			// Record
 			agg.Add(e)
@@ -164,40 +332,99 @@ func runCmd(args []string) error {
 	if w := prices.StaleWarning(); w != "" {
 		summary.Warnings = append(summary.Warnings, w)
 	}
+	for _, w := range rules.Warnings(summary) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		summary.Warnings = append(summary.Warnings, w)
+	}
+	applyCurrency(&summary, *currencyCode, rates)
 
 	// Write summary file
 	if err := ledger.WriteSummary("plarix-summary.json", summary); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to write summary: %v\n", err)
 	}
 
-	// Generate report
-	report := generateReport(summary, prices.AsOf)
+	// Check budgets and generate report
+	budgets := budget.Check(summary, rules)
+	if *junitPath != "" {
+		if err := writeJUnit(*junitPath, budgets); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write JUnit report: %v\n", err)
+		}
+	}
+	data := report.Data{
+		Summary: summary,
+		Meta:    report.Meta{Version: version, PricesAsOf: prices.AsOf},
+		Delta:   compare(summary, baseline),
+		Budgets: budgets,
+	}
+	md := renderReport(tmpl, data)
+	if *badgePath != "" {
+		if err := writeBadge(*badgePath, data); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write badge: %v\n", err)
+		}
+	}
 
-	// Output based on comment mode
+	// Output based on comment mode, on GitHub or GitLab
+	platform := action.Detect()
 	if *commentMode == "summary" || *commentMode == "both" {
-		if err := action.WriteStepSummary(report); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to write step summary: %v\n", err)
+		if err := platform.WriteSummary(md); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write %s job summary: %v\n", platform.Name(), err)
 		}
 	}
 
-	// Post PR comment if in PR context
+	// Post PR/MR comment if in PR/MR context
 	if *commentMode == "pr" || *commentMode == "both" {
+		if platform.InChangeRequest() {
+			if err := platform.PostComment(md); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to post %s comment: %v\n", platform.Name(), err)
+			} else {
+				fmt.Printf("Posted/updated %s comment\n", platform.Name())
+			}
+		} else {
+			fmt.Printf("Not in a %s %s, skipping %s comment\n", platform.Name(), platform.ChangeRequest(), platform.ChangeRequest())
+		}
+	}
+
+	// Create check run if in PR context
+	if *checkRun {
 		if pr := action.GetPRInfo(); pr != nil {
-			if err := action.PostComment(pr, report); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to post PR comment: %v\n", err)
+			if err := action.CreateCheckRun(pr, checkRunFor(summary, budgets, md)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to create check run: %v\n", err)
+			} else {
+				fmt.Printf("Created %q check run\n", action.CheckName)
+			}
+		} else {
+			fmt.Println("Not in PR context, skipping check run")
+		}
+	}
+
+	// Set commit status, with or without a PR
+	if *commitStatus {
+		if repo := action.GetRepoInfo(); repo != nil {
+			state, description := commitStatusFor(summary, budgets)
+			if err := action.SetCommitStatus(repo, state, description); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to set commit status: %v\n", err)
 			} else {
-				fmt.Println("Posted/updated PR comment")
+				fmt.Printf("Set %q commit status\n", action.CheckName)
 			}
 		} else {
-			fmt.Println("Not in PR context, skipping PR comment")
+			fmt.Println("Not in GitHub Actions, skipping commit status")
+		}
+	}
+
+	// Post results to a webhook, for any CI
+	if *webhookURL != "" {
+		payload := webhookPayload{Version: version, Summary: summary, Markdown: md, Budgets: budgets, Passed: budget.Err(budgets) == nil}
+		if err := action.PostWebhook(*webhookURL, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to post webhook: %v\n", err)
+		} else {
+			fmt.Println("Posted results to webhook")
 		}
 	}
 
-	fmt.Println(report)
+	fmt.Println(md)
 
-	// Check cost threshold
-	if *failOnCost > 0 && summary.TotalKnownCostUSD > *failOnCost {
-		return fmt.Errorf("cost threshold exceeded: $%.4f > $%.4f", summary.TotalKnownCostUSD, *failOnCost)
+	if err := budget.Err(budgets); err != nil {
+		return err
 	}
 
 	// Return command error if any
@@ -213,51 +440,101 @@ func runProxy(args []string) error {
 
 	portFlag := fs.Int("port", 8080, "Port to listen on")
 	pricingPath := fs.String("pricing", "", "Path to custom pricing JSON")
+	var pricingOverlays stringList
+	fs.Var(&pricingOverlays, "pricing-overlay", "Pricing JSON layered over the table (repeatable)")
 	ledgerPath := fs.String("ledger", "plarix-ledger.jsonl", "Path to ledger file")
 	providers := fs.String("providers", "openai,anthropic,openrouter", "Providers to intercept")
+	var tagFlags stringList
+	fs.Var(&tagFlags, "tag", "Tag recorded on every call, key=value (repeatable)")
+	rotateSize := fs.String("rotate-size", "", "Rotate the ledger when it reaches this size (e.g. 100MB)")
+	rotateInterval := fs.String("rotate-interval", "", "Rotate the ledger hourly, daily, or every duration (e.g. 6h)")
+	compressRotated := fs.Bool("compress-rotated", false, "Gzip rotated ledger segments")
+	retainAge := fs.String("retain-age", "", "Delete rotated segments older than this (e.g. 30d, 720h)")
+	retainSegments := fs.Int("retain-segments", 0, "Keep at most this many rotated segments")
+	fsyncPolicy := fs.String("fsync", "interval", "When to fsync the ledger: always, interval, never")
+	fsyncInterval := fs.Duration("fsync-interval", ledger.DefaultSyncInterval, "fsync period with --fsync interval")
+	hashChain := fs.Bool("hash-chain", false, "Chain entries by hash (HMAC with $"+ledger.HMACKeyEnv+" if set)")
+	storePath := fs.String("store", "", "Also record entries in an indexed store and serve the query API")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
 	// Load pricing
-	prices, err := loadPricing(*pricingPath)
+	prices, err := loadPricing(*pricingPath, pricingOverlays)
 	if err != nil {
 		return fmt.Errorf("load pricing: %w", err)
 	}
 
+	tags, err := ledger.ParseTags(strings.Join(tagFlags, ","))
+	if err != nil {
+		return err
+	}
+
+	opts := ledger.Options{
+		Compress:     *compressRotated,
+		MaxSegments:  *retainSegments,
+		SyncInterval: *fsyncInterval,
+		Chain:        *hashChain,
+	}
+	if *hashChain {
+		opts.HMACKey = hmacKey()
+	}
+	if opts.Sync, err = ledger.ParseSyncPolicy(*fsyncPolicy); err != nil {
+		return err
+	}
+	if opts.MaxBytes, err = ledger.ParseSize(*rotateSize); err != nil {
+		return err
+	}
+	if opts.Interval, err = ledger.ParseInterval(*rotateInterval); err != nil {
+		return err
+	}
+	if opts.MaxAge, err = ledger.ParseAge(*retainAge); err != nil {
+		return err
+	}
+
 	// Create aggregator and writer
-	writer, err := ledger.NewWriter(*ledgerPath)
+	writer, err := ledger.OpenWriter(*ledgerPath, opts)
 	if err != nil {
 		return fmt.Errorf("create ledger writer: %w", err)
 	}
 	defer writer.Close()
 
+	var store *ledger.DB
+	if *storePath != "" {
+		store, err = ledger.OpenDB(*storePath, ledger.Options{Sync: opts.Sync, SyncInterval: opts.SyncInterval})
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
+		}
+		defer store.Close()
+	}
+
 	// Start proxy
 	proxyConfig := proxy.Config{
 		Providers: strings.Split(*providers, ","),
+		Tags:      tags,
 		OnEntry: func(e ledger.Entry) {
-			// Compute cost
-			if e.CostKnown && e.Model != "" {
-				result := prices.ComputeCost(e.Model, e.InputTokens, e.OutputTokens)
-				if result.Known {
-					e.CostUSD = result.CostUSD
-				} else {
-					e.CostKnown = false
-					e.UnknownReason = result.UnknownReason
-				}
-			}
+			applyCost(prices, &e)
 
 			// Record
 			// In proxy mode, we might just log to stdout as well
-			fmt.Printf("Recorded call: %s %s tokens=%d/%d cost=$%.4f\n",
+			fmt.Printf("Recorded call: %s %s tokens=%d/%d cost=$%s\n",
 				e.Provider, e.Model, e.InputTokens, e.OutputTokens, e.CostUSD)
 
 			if err := writer.Write(e); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to write ledger entry: %v\n", err)
 			}
+			if store != nil {
+				if err := store.Write(e); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to write store entry: %v\n", err)
+				}
+			}
 		},
 	}
+	if store != nil {
+		proxyConfig.Store = store
+		proxyConfig.APIToken = os.Getenv("PLARIX_API_TOKEN")
+	}
 
 	// Start proxy
 	server := proxy.NewServer(proxyConfig)
@@ -269,6 +546,12 @@ func runProxy(args []string) error {
 
 	fmt.Printf("Plarix proxy running on port %d\n", actualPort)
 	fmt.Printf("Ledger: %s\n", *ledgerPath)
+	if store != nil {
+		fmt.Printf("Store: %s (%d entries), query API at %s\n", *storePath, store.Len(), proxy.APIPrefix)
+		if proxyConfig.APIToken == "" {
+			fmt.Println("Query API: loopback clients only (set PLARIX_API_TOKEN to allow others)")
+		}
+	}
 	fmt.Println("Press Ctrl+C to stop...")
 
 	// Wait for signal
@@ -280,90 +563,375 @@ func runProxy(args []string) error {
 	return nil
 }
 
-func loadPricing(customPath string) (*pricing.Prices, error) {
-	path := customPath
-	if path == "" {
-		// Try to find bundled prices.json
-		exe, _ := os.Executable()
-		candidates := []string{
-			filepath.Join(filepath.Dir(exe), "prices", "prices.json"),
-			filepath.Join(filepath.Dir(exe), "..", "prices", "prices.json"),
-			"prices/prices.json",
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+
+	ledgerPath := fs.String("ledger", "plarix-ledger.jsonl", "Path to ledger file")
+	pricingPath := fs.String("pricing", "", "Path to custom pricing JSON")
+	var pricingOverlays stringList
+	fs.Var(&pricingOverlays, "pricing-overlay", "Pricing JSON layered over the table (repeatable)")
+	reprice := fs.Bool("reprice", false, "Recompute costs with the prices in effect at each entry's timestamp")
+	currencyCode := fs.String("currency", "", "Display costs in this currency (e.g. EUR)")
+	fxRatesPath := fs.String("fx-rates", "", "Exchange-rate JSON used with --currency")
+	storePath := fs.String("store", "", "Read an indexed store (proxy --store) instead of --ledger")
+	since := fs.String("since", "", "Only entries at or after this time (RFC 3339 or YYYY-MM-DD)")
+	until := fs.String("until", "", "Only entries before this time (RFC 3339 or YYYY-MM-DD)")
+	providerFilter := fs.String("provider", "", "Only entries from this provider")
+	modelFilter := fs.String("model", "", "Only entries for this model")
+	var tagFlags stringList
+	fs.Var(&tagFlags, "tag", "Only entries with this tag, key=value (repeatable)")
+	bucket := fs.String("bucket", "", "Add a time series: minute, hour, day or a duration")
+	format := fs.String("format", "markdown", "Output format: markdown, json or html")
+	outPath := fs.String("out", "", "Write the report to this file (default: stdout)")
+	templatePath := fs.String("report-template", "", "text/template file for the markdown report (default: built-in layout)")
+	baselinePath := fs.String("baseline", "", "plarix-summary.json of a previous run to compare costs with")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	q := ledger.Query{Provider: *providerFilter, Model: *modelFilter}
+	var err error
+	if q.Since, err = ledger.ParseTime(*since); err != nil {
+		return err
+	}
+	if q.Until, err = ledger.ParseTime(*until); err != nil {
+		return err
+	}
+	if len(tagFlags) > 0 {
+		if q.Tags, err = ledger.ParseTags(strings.Join(tagFlags, ",")); err != nil {
+			return err
+		}
+	}
+	bucketWidth, err := ledger.ParseBucketWidth(*bucket)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "markdown", "json":
+	case "html":
+		if bucketWidth == 0 {
+			bucketWidth = time.Hour // for the cost-over-time chart
+		}
+	default:
+		return fmt.Errorf("unknown format %q (want markdown, json or html)", *format)
+	}
+	tmpl, err := loadReportTemplate(*templatePath)
+	if err != nil {
+		return err
+	}
+	baseline, err := loadBaseline(*baselinePath)
+	if err != nil {
+		return err
+	}
+
+	prices, err := loadPricing(*pricingPath, pricingOverlays)
+	if err != nil {
+		return fmt.Errorf("load pricing: %w", err)
+	}
+
+	rates, err := loadRates(*currencyCode, *fxRatesPath)
+	if err != nil {
+		return fmt.Errorf("load exchange rates: %w", err)
+	}
+
+	agg := ledger.NewAggregator()
+	agg.SetBucketWidth(bucketWidth)
+	add := func(e ledger.Entry) error {
+		if *reprice {
+			repriceEntry(prices, &e)
+		}
+		agg.Add(e)
+		return nil
+	}
+
+	var torn []ledger.TornLine
+	if *storePath != "" {
+		store, err := ledger.OpenDBReadOnly(*storePath)
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
 		}
-		for _, c := range candidates {
-			if _, err := os.Stat(c); err == nil {
-				path = c
-				break
+		err = store.Query(q, add)
+		store.Close()
+		if err != nil {
+			return fmt.Errorf("query store: %w", err)
+		}
+	} else {
+		torn, err = ledger.ScanAll(*ledgerPath, func(e ledger.Entry) error {
+			if !q.Match(e) {
+				return nil
 			}
+			return add(e)
+		})
+		if err != nil {
+			return fmt.Errorf("read ledger: %w", err)
+		}
+	}
+
+	summary := agg.Summary()
+	for _, t := range torn {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", t)
+		summary.Warnings = append(summary.Warnings, t.String())
+	}
+	applyCurrency(&summary, *currencyCode, rates)
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
 		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case "html":
+		return report.HTML(out, summary, report.Meta{Version: version, PricesAsOf: prices.AsOf})
+	}
+	md, err := tmpl.Render(report.Data{
+		Summary: summary,
+		Meta:    report.Meta{Version: version, PricesAsOf: prices.AsOf},
+		Delta:   compare(summary, baseline),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, md)
+	return err
+}
+
+// loadRates loads the exchange-rate file when a non-USD currency is requested
+// and checks that it has a rate for that currency.
+func loadRates(code, path string) (*currency.Rates, error) {
+	if code == "" || strings.EqualFold(code, "USD") {
+		return nil, nil
 	}
 	if path == "" {
-		return nil, fmt.Errorf("pricing file not found")
+		return nil, fmt.Errorf("--currency %s requires --fx-rates", code)
 	}
-	return pricing.Load(path)
+	rates, err := currency.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rates.Rate(code); err != nil {
+		return nil, err
+	}
+	return rates, nil
 }
 
-func runUserCommand(command string, envVars map[string]string) error {
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// applyCurrency switches the summary to the display currency, if any.
+func applyCurrency(s *ledger.Summary, code string, rates *currency.Rates) {
+	if rates == nil {
+		return
+	}
+	rate, _ := rates.Rate(code)
+	s.SetCurrency(ledger.Currency{
+		Code:      strings.ToUpper(code),
+		PerUSD:    rate,
+		RatesAsOf: rates.AsOf,
+	})
+	if w := rates.StaleWarning(); w != "" {
+		s.Warnings = append(s.Warnings, w)
+	}
+}
 
-	// Copy current env and add overrides
-	cmd.Env = os.Environ()
-	for k, v := range envVars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+// loadReportTemplate parses the report template at path, or returns the
+// default template if path is empty.
+func loadReportTemplate(path string) (*report.Template, error) {
+	if path == "" {
+		return report.Default, nil
 	}
+	return report.LoadTemplate(path)
+}
 
-	return cmd.Run()
+// loadBaseline reads the summary of a baseline run, if a path is given.
+func loadBaseline(path string) (*ledger.Summary, error) {
+	if path == "" {
+		return nil, nil
+	}
+	s, err := ledger.ReadSummary(path)
+	if err != nil {
+		return nil, fmt.Errorf("load baseline: %w", err)
+	}
+	return &s, nil
 }
 
-func generateReport(s ledger.Summary, pricesAsOf string) string {
-	var b strings.Builder
+// compare returns the change from baseline to s, or nil without a baseline.
+func compare(s ledger.Summary, baseline *ledger.Summary) *report.Delta {
+	if baseline == nil {
+		return nil
+	}
+	return report.Compare(s, *baseline)
+}
+
+// writeJUnit writes the budget results as JUnit XML.
+func writeJUnit(path string, results []budget.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := budget.WriteJUnit(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	b.WriteString("## Plarix Scan Cost Report\n\n")
-	fmt.Fprintf(&b, "**Total Known Cost:** $%.4f USD\n", s.TotalKnownCostUSD)
-	fmt.Fprintf(&b, "**Calls Observed:** %d\n", s.TotalCalls)
-	fmt.Fprintf(&b, "**Tokens:** %d in / %d out\n\n", s.TotalInputTokens, s.TotalOutputTokens)
+// webhookPayload is the body --webhook-url receives.
+type webhookPayload struct {
+	Version  string          `json:"version"`
+	Summary  ledger.Summary  `json:"summary"`
+	Markdown string          `json:"markdown"`
+	Budgets  []budget.Result `json:"budgets"`
+	Passed   bool            `json:"passed"` // all budget rules passed
+}
 
-	if s.UnknownCostCalls > 0 {
-		fmt.Fprintf(&b, "**Unknown Cost Calls:** %d\n", s.UnknownCostCalls)
-		if len(s.UnknownReasons) > 0 {
-			for reason, count := range s.UnknownReasons {
-				fmt.Fprintf(&b, "  - %s: %d\n", reason, count)
-			}
+// checkRunFor builds the check run of a finished run: it fails when a budget
+// rule failed, with an annotation on the workflow line that set the rule.
+func checkRunFor(s ledger.Summary, budgets []budget.Result, md string) action.CheckRun {
+	run := action.CheckRun{
+		Conclusion: action.ConclusionSuccess,
+		Title:      fmt.Sprintf("%s known cost, %d calls", report.Data{Summary: s}.TotalCost(), s.TotalCalls),
+		Summary:    md,
+	}
+	failed := 0
+	for _, r := range budgets {
+		if r.Passed {
+			continue
+		}
+		failed++
+		if a, ok := action.WorkflowAnnotation("Budget exceeded: "+r.Rule, r.Message, budgetKeys(r.Rule)...); ok {
+			run.Annotations = append(run.Annotations, a)
 		}
-		b.WriteString("\n")
 	}
+	if failed > 0 {
+		run.Conclusion = action.ConclusionFailure
+		run.Title += fmt.Sprintf(", %d of %d budget rules failed", failed, len(budgets))
+	}
+	return run
+}
 
-	if s.TotalCalls == 0 {
-		b.WriteString("No real provider calls observed. Tests may be stubbed.\n\n")
+// commitStatusFor returns the commit status of a finished run, e.g.
+// "LLM cost: $0.42/run, 37 calls".
+func commitStatusFor(s ledger.Summary, budgets []budget.Result) (state, description string) {
+	state = action.StatusSuccess
+	description = fmt.Sprintf("LLM cost: %s known, %d calls", report.Data{Summary: s}.TotalCost(), s.TotalCalls)
+	if err := budget.Err(budgets); err != nil {
+		state = action.StatusFailure
+		description += "; " + strings.ReplaceAll(err.Error(), "\n", "; ")
 	}
+	return state, description
+}
 
-	// Model breakdown table (top 6)
-	if len(s.ModelBreakdown) > 0 {
-		b.WriteString("| Model | Calls | Tokens (in/out) | Known Cost |\n")
-		b.WriteString("|-------|-------|-----------------|------------|\n")
+// budgetKeys returns the action input and flag that set a budget rule, to
+// find the rule in the workflow file.
+func budgetKeys(rule string) []string {
+	switch {
+	case rule == "total cost":
+		return []string{"fail_on_cost_usd", "--fail-on-cost"}
+	case strings.HasPrefix(rule, "model "):
+		return []string{"model_caps", "--model-cap"}
+	case rule == "unknown cost":
+		return []string{"unknown_cost", "--unknown-cost"}
+	}
+	return nil
+}
 
-		count := 0
-		for model, stats := range s.ModelBreakdown {
-			if count >= 6 {
-				break
-			}
-			fmt.Fprintf(&b, "| %s | %d | %d / %d | $%.4f |\n",
-				model, stats.Calls, stats.InputTokens, stats.OutputTokens, stats.KnownCostUSD)
-			count++
+// writeBadge writes the run's SVG cost badge.
+func writeBadge(path string, d report.Data) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Badge(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderReport renders the run's report. A template that fails on this
+// data falls back to the default layout, so the run is still reported.
+func renderReport(tmpl *report.Template, d report.Data) string {
+	md, err := tmpl.Render(d)
+	if err != nil && tmpl != report.Default {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using the default report\n", err)
+		md, err = report.Default.Render(d)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return md
+}
+
+// defaultPricingPath is where pricing sync writes the table, and where the
+// other commands look for it before falling back to the bundled one.
+const defaultPricingPath = "prices/prices.json"
+
+// loadPricing returns the custom table at customPath, else the table at
+// defaultPricingPath if there is one, else the bundled table, with each
+// overlay file layered on top in order.
+func loadPricing(customPath string, overlays []string) (*pricing.Prices, error) {
+	if customPath == "" {
+		if _, err := os.Stat(defaultPricingPath); err == nil {
+			customPath = defaultPricingPath
+		}
+	}
+	var prices *pricing.Prices
+	var err error
+	if customPath != "" {
+		prices, err = pricing.Load(customPath)
+	} else {
+		prices, err = pricing.Default()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range overlays {
+		overlay, err := pricing.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %w", path, err)
 		}
-		b.WriteString("\n")
+		prices.Overlay(overlay)
 	}
+	return prices, nil
+}
+
+// stringList is a repeatable flag; each value may also be comma-separated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	// Warnings
-	for _, w := range s.Warnings {
-		b.WriteString(w + "\n")
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
 	}
+	return nil
+}
+
+func runUserCommand(command string, envVars map[string]string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
 
-	// Footer
-	fmt.Fprintf(&b, "\n---\n*Plarix Scan v%s | Prices as of %s | %s*\n",
-		version, pricesAsOf, time.Now().UTC().Format("2006-01-02 15:04 UTC"))
+	// Copy current env and add overrides
+	cmd.Env = os.Environ()
+	for k, v := range envVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
 
-	return b.String()
+	return cmd.Run()
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"plarix-action/internal/action"
+	"plarix-action/internal/budget"
+	"plarix-action/internal/ledger"
+)
+
+func TestBudgetKeys(t *testing.T) {
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"total cost", []string{"fail_on_cost_usd", "--fail-on-cost"}},
+		{"model gpt-4o", []string{"model_caps", "--model-cap"}},
+		{"unknown cost", []string{"unknown_cost", "--unknown-cost"}},
+		{"something else", nil},
+	}
+	for _, tt := range tests {
+		if got := budgetKeys(tt.rule); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("budgetKeys(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRunFor(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	workflow := "steps:\n  - uses: plarix/scan@v1\n    with:\n      fail_on_cost_usd: 1\n      model_caps: gpt-4o=0.5\n"
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_WORKSPACE", dir)
+	t.Setenv("GITHUB_WORKFLOW_REF", "o/r/.github/workflows/ci.yml@refs/pull/7/merge")
+
+	s := ledger.Summary{TotalCalls: 3}
+	passed := []budget.Result{{Rule: "total cost", Passed: true}}
+	failed := []budget.Result{
+		{Rule: "total cost", Passed: true},
+		{Rule: "model gpt-4o", Passed: false, Message: "model gpt-4o cost cap exceeded"},
+	}
+
+	run := checkRunFor(s, passed, "report")
+	if run.Conclusion != action.ConclusionSuccess || len(run.Annotations) != 0 || run.Summary != "report" {
+		t.Errorf("passing run = %+v, want a success without annotations", run)
+	}
+
+	run = checkRunFor(s, failed, "report")
+	if run.Conclusion != action.ConclusionFailure {
+		t.Errorf("conclusion = %q, want %q", run.Conclusion, action.ConclusionFailure)
+	}
+	if want := "$0.0000 USD known cost, 3 calls, 1 of 2 budget rules failed"; run.Title != want {
+		t.Errorf("title = %q, want %q", run.Title, want)
+	}
+	if len(run.Annotations) != 1 {
+		t.Fatalf("annotations = %+v, want one", run.Annotations)
+	}
+	if a := run.Annotations[0]; a.Path != ".github/workflows/ci.yml" || a.Line != 5 || a.Title != "Budget exceeded: model gpt-4o" {
+		t.Errorf("annotation = %+v, want the model_caps line", a)
+	}
+}
+
+func TestLoadPricingPrefersSyncedTable(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	bundled, err := loadPricing("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bundled.PriceAt("synced-model", time.Now()); ok {
+		t.Fatal("bundled table prices synced-model")
+	}
+
+	if err := os.MkdirAll("prices", 0755); err != nil {
+		t.Fatal(err)
+	}
+	table := `{"as_of":"2026-10-01","models":{"synced-model":{"input_per_1k":1,"output_per_1k":2}}}`
+	if err := os.WriteFile(defaultPricingPath, []byte(table), 0644); err != nil {
+		t.Fatal(err)
+	}
+	synced, err := loadPricing("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := synced.PriceAt("synced-model", time.Now()); !ok {
+		t.Errorf("loadPricing() ignored %s", defaultPricingPath)
+	}
+
+	if err := os.WriteFile(defaultPricingPath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPricing("", nil); err == nil {
+		t.Errorf("loadPricing() with a broken %s succeeded", defaultPricingPath)
+	}
+}
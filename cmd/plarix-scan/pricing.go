@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"plarix-action/internal/pricing"
+)
+
+// runPricing dispatches the `pricing` subcommands.
+func runPricing(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: plarix-scan pricing sync --from <url|file>")
+	}
+
+	switch args[0] {
+	case "sync":
+		return runPricingSync(args[1:])
+	default:
+		return fmt.Errorf("unknown pricing command: %s", args[0])
+	}
+}
+
+// runPricingSync updates a pricing file from a provider price feed and prints
+// the models whose prices were added or changed.
+func runPricingSync(args []string) error {
+	fs := flag.NewFlagSet("pricing sync", flag.ExitOnError)
+
+	from := fs.String("from", "", "Price feed URL or file (required)")
+	format := fs.String("format", "auto", "Feed format: auto, openrouter, litellm")
+	pricingPath := fs.String("pricing", defaultPricingPath, "Pricing file to update")
+	outPath := fs.String("out", "", "Where to write the updated table (default: --pricing)")
+	addNew := fs.Bool("add-new", false, "Also add models that are not in the table yet")
+	dryRun := fs.Bool("dry-run", false, "Show the diff without writing")
+	asOfFlag := fs.String("as-of", "", "Date of the snapshot, YYYY-MM-DD (default: today)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("--from is required")
+	}
+	if *outPath == "" {
+		*outPath = *pricingPath
+	}
+
+	asOf := time.Now().UTC()
+	if *asOfFlag != "" {
+		parsed, err := time.Parse("2006-01-02", *asOfFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --as-of: %w", err)
+		}
+		asOf = parsed
+	}
+
+	prices, err := pricing.Load(*pricingPath)
+	if err != nil {
+		return fmt.Errorf("load pricing: %w", err)
+	}
+
+	data, err := pricing.FetchFeed(*from)
+	if err != nil {
+		return fmt.Errorf("fetch feed: %w", err)
+	}
+	feed, err := pricing.ParseFeed(data, *format)
+	if err != nil {
+		return err
+	}
+
+	changes := prices.Sync(feed, asOf, *addNew)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	fmt.Printf("%d models changed (%d in feed)\n", len(changes), len(feed))
+
+	if *dryRun {
+		return nil
+	}
+	if err := prices.Save(*outPath); err != nil {
+		return fmt.Errorf("write pricing: %w", err)
+	}
+	fmt.Printf("Wrote %s (as_of %s)\n", *outPath, prices.AsOf)
+	return nil
+}
@@ -1,16 +1,21 @@
-// Package action handles GitHub Actions-specific functionality.
+// Package action handles CI platform-specific functionality.
 //
-// Purpose: Post PR comments, write Step Summaries, interact with GitHub API.
-// Public API: PostComment, WriteStepSummary, GetPRNumber
-// Usage: Use PostComment to upsert a PR comment with a marker for idempotency.
+// Purpose: Post PR comments, write Step Summaries, create check runs and
+// commit statuses through a small GitHub API client (pagination, timeouts,
+// retries); on GitLab CI and Bitbucket Pipelines, post merge request notes
+// or pull request comments and a report artifact; on any CI, POST the
+// results to a signed webhook.
+// Public API: Platform, Detect, ReportFile, RepoInfo, PRInfo, GetPRInfo,
+// GetRepoInfo, PostComment, WriteStepSummary, CheckRun, Annotation,
+// CreateCheckRun, WorkflowAnnotation, SetCommitStatus, PostWebhook,
+// WebhookSignatureHeader
+// Usage: Detect the platform, then PostComment to upsert the report comment
+// (marker-tagged, so reruns update it) and WriteSummary.
 package action
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -19,37 +24,64 @@ import (
 
 const commentMarker = "<!-- plarix-scan -->"
 
+// RepoInfo holds GitHub repository context from environment.
+type RepoInfo struct {
+	Owner   string
+	Repo    string
+	HeadSHA string // head commit of the PR, or the commit being built
+	Token   string
+	APIURL  string
+}
+
 // PRInfo holds GitHub PR context from environment.
 type PRInfo struct {
-	Owner  string
-	Repo   string
+	RepoInfo
 	Number int
-	Token  string
-	APIURL string
 }
 
 // GetPRInfo extracts PR information from GitHub Actions environment.
 // Returns nil if not in a PR context.
 func GetPRInfo() *PRInfo {
+	repo, prNumber := getRepoContext()
+	if repo == nil || prNumber == 0 {
+		return nil
+	}
+	return &PRInfo{RepoInfo: *repo, Number: prNumber}
+}
+
+// GetRepoInfo returns the repository context with or without a PR, such as
+// on pushes to the default branch. Returns nil if not in GitHub Actions,
+// without a token or without a commit.
+func GetRepoInfo() *RepoInfo {
+	repo, _ := getRepoContext()
+	if repo == nil || repo.HeadSHA == "" {
+		return nil
+	}
+	return repo
+}
+
+// getRepoContext extracts the repository context and the PR number (0
+// outside a PR) from the environment.
+func getRepoContext() (*RepoInfo, int) {
 	token := os.Getenv("GITHUB_TOKEN")
 	if token == "" {
-		return nil
+		return nil, 0
 	}
 
 	repo := os.Getenv("GITHUB_REPOSITORY")
 	if repo == "" {
-		return nil
+		return nil, 0
 	}
 
 	parts := strings.SplitN(repo, "/", 2)
 	if len(parts) != 2 {
-		return nil
+		return nil, 0
 	}
 
 	// Get PR number from event
-	prNumber := getPRNumber()
-	if prNumber == 0 {
-		return nil
+	prNumber, headSHA := getPRNumber()
+	if headSHA == "" {
+		headSHA = os.Getenv("GITHUB_SHA")
 	}
 
 	apiURL := os.Getenv("GITHUB_API_URL")
@@ -57,175 +89,122 @@ func GetPRInfo() *PRInfo {
 		apiURL = "https://api.github.com"
 	}
 
-	return &PRInfo{
-		Owner:  parts[0],
-		Repo:   parts[1],
-		Number: prNumber,
-		Token:  token,
-		APIURL: apiURL,
-	}
+	return &RepoInfo{
+		Owner:   parts[0],
+		Repo:    parts[1],
+		HeadSHA: headSHA,
+		Token:   token,
+		APIURL:  apiURL,
+	}, prNumber
 }
 
-// getPRNumber extracts PR number from GitHub event context.
-func getPRNumber() int {
-	// Try GITHUB_REF_NAME for pull_request events
-	refName := os.Getenv("GITHUB_REF_NAME")
-	if strings.Contains(refName, "/merge") {
-		// Format: <pr_number>/merge
-		parts := strings.Split(refName, "/")
-		if len(parts) > 0 {
-			if n, err := strconv.Atoi(parts[0]); err == nil {
-				return n
-			}
-		}
-	}
-
+// getPRNumber extracts PR number from GitHub event context, and the PR's
+// head commit when the event payload has it.
+func getPRNumber() (int, string) {
 	// Try parsing event payload
-	eventPath := os.Getenv("GITHUB_EVENT_PATH")
-	if eventPath == "" {
-		return 0
-	}
-
-	data, err := os.ReadFile(eventPath)
-	if err != nil {
-		return 0
-	}
-
 	var event struct {
 		PullRequest struct {
 			Number int `json:"number"`
+			Head   struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
 		} `json:"pull_request"`
 		Issue struct {
 			Number int `json:"number"`
 		} `json:"issue"`
 		Number int `json:"number"`
 	}
+	if eventPath := os.Getenv("GITHUB_EVENT_PATH"); eventPath != "" {
+		if data, err := os.ReadFile(eventPath); err == nil {
+			json.Unmarshal(data, &event)
+		}
+	}
+	headSHA := event.PullRequest.Head.SHA
 
-	if err := json.Unmarshal(data, &event); err != nil {
-		return 0
+	// Try GITHUB_REF_NAME for pull_request events
+	refName := os.Getenv("GITHUB_REF_NAME")
+	if strings.Contains(refName, "/merge") {
+		// Format: <pr_number>/merge
+		parts := strings.Split(refName, "/")
+		if len(parts) > 0 {
+			if n, err := strconv.Atoi(parts[0]); err == nil {
+				return n, headSHA
+			}
+		}
 	}
 
 	if event.PullRequest.Number > 0 {
-		return event.PullRequest.Number
+		return event.PullRequest.Number, headSHA
 	}
 	if event.Issue.Number > 0 {
-		return event.Issue.Number
+		return event.Issue.Number, headSHA
 	}
-	return event.Number
+	return event.Number, headSHA
 }
 
 // PostComment creates or updates a PR comment with the given content.
 // Uses the marker to find and update existing comments (idempotent).
 func PostComment(pr *PRInfo, content string) error {
 	content = commentMarker + "\n" + content
+	c := newClient(&pr.RepoInfo)
 
 	// Find existing comment with marker
-	existingID, err := findExistingComment(pr)
+	existingID, err := findExistingComment(c, pr)
 	if err != nil {
 		return fmt.Errorf("find existing comment: %w", err)
 	}
 
 	if existingID > 0 {
-		return updateComment(pr, existingID, content)
+		return updateComment(c, pr, existingID, content)
 	}
-	return createComment(pr, content)
+	return createComment(c, pr, content)
 }
 
-// findExistingComment looks for a comment with our marker.
-func findExistingComment(pr *PRInfo) (int64, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments",
-		pr.APIURL, pr.Owner, pr.Repo, pr.Number)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, err
-	}
-	req.Header.Set("Authorization", "Bearer "+pr.Token)
-	req.Header.Set("Accept", "application/vnd.github+json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("list comments failed: %s - %s", resp.Status, string(body))
-	}
+var markerRegex = regexp.MustCompile(`<!--\s*plarix-scan\s*-->`)
 
-	var comments []struct {
-		ID   int64  `json:"id"`
-		Body string `json:"body"`
-	}
+// findExistingComment looks for a comment with our marker on every page of
+// the PR's comments.
+func findExistingComment(c *client, pr *PRInfo) (int64, error) {
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments?per_page=100", pr.Owner, pr.Repo, pr.Number)
 
-	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
-		return 0, err
-	}
-
-	markerRegex := regexp.MustCompile(`<!--\s*plarix-scan\s*-->`)
-	for _, c := range comments {
-		if markerRegex.MatchString(c.Body) {
-			return c.ID, nil
+	var found int64
+	err := c.list(path, func(page json.RawMessage) (bool, error) {
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal(page, &comments); err != nil {
+			return false, err
 		}
+		for _, comment := range comments {
+			if markerRegex.MatchString(comment.Body) {
+				found = comment.ID
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("list comments failed: %w", err)
 	}
-
-	return 0, nil
+	return found, nil
 }
 
 // createComment creates a new PR comment.
-func createComment(pr *PRInfo, content string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments",
-		pr.APIURL, pr.Owner, pr.Repo, pr.Number)
-
-	body, _ := json.Marshal(map[string]string{"body": content})
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", "Bearer "+pr.Token)
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("create comment failed: %s - %s", resp.Status, string(respBody))
+func createComment(c *client, pr *PRInfo, content string) error {
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", pr.Owner, pr.Repo, pr.Number)
+	if _, err := c.do("POST", path, map[string]string{"body": content}, nil); err != nil {
+		return fmt.Errorf("create comment failed: %w", err)
 	}
-
 	return nil
 }
 
 // updateComment updates an existing PR comment.
-func updateComment(pr *PRInfo, commentID int64, content string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/issues/comments/%d",
-		pr.APIURL, pr.Owner, pr.Repo, commentID)
-
-	body, _ := json.Marshal(map[string]string{"body": content})
-	req, err := http.NewRequest("PATCH", url, bytes.NewReader(body))
-	if err != nil {
-		return err
+func updateComment(c *client, pr *PRInfo, commentID int64, content string) error {
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", pr.Owner, pr.Repo, commentID)
+	if _, err := c.do("PATCH", path, map[string]string{"body": content}, nil); err != nil {
+		return fmt.Errorf("update comment failed: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+pr.Token)
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("update comment failed: %s - %s", resp.Status, string(respBody))
-	}
-
 	return nil
 }
 
@@ -0,0 +1,114 @@
+package action
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// bitbucket reports to Bitbucket Pipelines: comments on Bitbucket Cloud
+// pull requests, and the report as a build artifact.
+type bitbucket struct {
+	apiURL    string // Bitbucket Cloud's API
+	workspace string // BITBUCKET_WORKSPACE
+	repoSlug  string // BITBUCKET_REPO_SLUG
+	prID      string // BITBUCKET_PR_ID; empty outside pull request pipelines
+	dir       string // BITBUCKET_CLONE_DIR
+
+	// Either an access token (BITBUCKET_TOKEN), or a username and app
+	// password (BITBUCKET_USERNAME, BITBUCKET_APP_PASSWORD).
+	token, username, password string
+}
+
+func detectBitbucket() *bitbucket {
+	return &bitbucket{
+		apiURL:    "https://api.bitbucket.org/2.0",
+		workspace: os.Getenv("BITBUCKET_WORKSPACE"),
+		repoSlug:  os.Getenv("BITBUCKET_REPO_SLUG"),
+		prID:      os.Getenv("BITBUCKET_PR_ID"),
+		dir:       os.Getenv("BITBUCKET_CLONE_DIR"),
+		token:     os.Getenv("BITBUCKET_TOKEN"),
+		username:  os.Getenv("BITBUCKET_USERNAME"),
+		password:  os.Getenv("BITBUCKET_APP_PASSWORD"),
+	}
+}
+
+func (*bitbucket) Name() string { return "Bitbucket" }
+
+func (*bitbucket) ChangeRequest() string { return "pull request" }
+
+func (b *bitbucket) InChangeRequest() bool {
+	return b.workspace != "" && b.repoSlug != "" && b.prID != ""
+}
+
+// PostComment creates or updates the pull request comment with the marker.
+func (b *bitbucket) PostComment(content string) error {
+	c := &client{
+		http:   &http.Client{Timeout: requestTimeout},
+		apiURL: strings.TrimSuffix(b.apiURL, "/"),
+		header: http.Header{"Accept": {"application/json"}},
+	}
+	switch {
+	case b.token != "":
+		c.header.Set("Authorization", "Bearer "+b.token)
+	case b.username != "" && b.password != "":
+		c.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(b.username+":"+b.password)))
+	default:
+		return fmt.Errorf("BITBUCKET_TOKEN (or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD) is not set")
+	}
+	content = commentMarker + "\n" + content
+	comments := fmt.Sprintf("/repositories/%s/%s/pullrequests/%s/comments",
+		url.PathEscape(b.workspace), url.PathEscape(b.repoSlug), url.PathEscape(b.prID))
+
+	existingID, err := b.findExistingComment(c, comments+"?pagelen=100")
+	if err != nil {
+		return fmt.Errorf("find existing comment: %w", err)
+	}
+
+	body := map[string]interface{}{"content": map[string]string{"raw": content}}
+	if existingID > 0 {
+		if _, err := c.do("PUT", fmt.Sprintf("%s/%d", comments, existingID), body, nil); err != nil {
+			return fmt.Errorf("update comment failed: %w", err)
+		}
+		return nil
+	}
+	if _, err := c.do("POST", comments, body, nil); err != nil {
+		return fmt.Errorf("create comment failed: %w", err)
+	}
+	return nil
+}
+
+// findExistingComment looks for a comment with our marker, following the
+// "next" links of Bitbucket's paginated responses.
+func (b *bitbucket) findExistingComment(c *client, path string) (int64, error) {
+	for path != "" {
+		var page struct {
+			Values []struct {
+				ID      int64 `json:"id"`
+				Deleted bool  `json:"deleted"`
+				Content struct {
+					Raw string `json:"raw"`
+				} `json:"content"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if _, err := c.do("GET", path, nil, &page); err != nil {
+			return 0, fmt.Errorf("list comments failed: %w", err)
+		}
+		for _, comment := range page.Values {
+			if !comment.Deleted && markerRegex.MatchString(comment.Content.Raw) {
+				return comment.ID, nil
+			}
+		}
+		path = page.Next
+	}
+	return 0, nil
+}
+
+// WriteSummary writes the report to ReportFile in the clone directory.
+func (b *bitbucket) WriteSummary(content string) error {
+	return writeReportFile(b.dir, content)
+}
@@ -0,0 +1,136 @@
+package action
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckName is the name of the check run created by CreateCheckRun. Branch
+// protection can require it like any other status check.
+const CheckName = "Plarix cost"
+
+// Limits of the check runs API.
+const (
+	maxCheckSummary     = 65535
+	maxCheckAnnotations = 50
+)
+
+// Check run conclusions.
+const (
+	ConclusionSuccess = "success"
+	ConclusionFailure = "failure"
+)
+
+// CheckRun is the result shown by a completed check run.
+type CheckRun struct {
+	Conclusion  string // ConclusionSuccess or ConclusionFailure
+	Title       string
+	Summary     string // markdown
+	Annotations []Annotation
+}
+
+// Annotation marks a line of a file in the check run.
+type Annotation struct {
+	Path    string `json:"path"`
+	Line    int    `json:"-"`
+	Level   string `json:"annotation_level"` // notice, warning or failure
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// CreateCheckRun creates a completed CheckName check run on the PR's head
+// commit. The token needs the checks: write permission.
+func CreateCheckRun(pr *PRInfo, run CheckRun) error {
+	if pr.HeadSHA == "" {
+		return fmt.Errorf("create check run: no head commit")
+	}
+
+	type annotation struct {
+		Annotation
+		StartLine int `json:"start_line"`
+		EndLine   int `json:"end_line"`
+	}
+	annotations := make([]annotation, 0, len(run.Annotations))
+	for i, a := range run.Annotations {
+		if i == maxCheckAnnotations {
+			break
+		}
+		annotations = append(annotations, annotation{a, a.Line, a.Line})
+	}
+	summary := run.Summary
+	if len(summary) > maxCheckSummary {
+		summary = strings.ToValidUTF8(summary[:maxCheckSummary-len("\n…")], "") + "\n…"
+	}
+
+	payload := map[string]interface{}{
+		"name":         CheckName,
+		"head_sha":     pr.HeadSHA,
+		"status":       "completed",
+		"conclusion":   run.Conclusion,
+		"completed_at": time.Now().UTC().Format(time.RFC3339),
+		"output": map[string]interface{}{
+			"title":       run.Title,
+			"summary":     summary,
+			"annotations": annotations,
+		},
+	}
+	path := fmt.Sprintf("/repos/%s/%s/check-runs", pr.Owner, pr.Repo)
+	if _, err := newClient(&pr.RepoInfo).do("POST", path, payload, nil); err != nil {
+		return fmt.Errorf("create check run failed: %w", err)
+	}
+	return nil
+}
+
+// WorkflowAnnotation returns a failure annotation on the running workflow
+// file, at the first line that mentions one of keys (such as the input that
+// set a budget), or at line 1. It returns false outside GitHub Actions.
+func WorkflowAnnotation(title, message string, keys ...string) (Annotation, bool) {
+	path := workflowFile()
+	if path == "" {
+		return Annotation{}, false
+	}
+	return Annotation{
+		Path:    path,
+		Line:    findLine(filepath.Join(os.Getenv("GITHUB_WORKSPACE"), path), keys),
+		Level:   "failure",
+		Title:   title,
+		Message: message,
+	}, true
+}
+
+// workflowFile returns the running workflow's path in the repository, from
+// GITHUB_WORKFLOW_REF ("owner/repo/.github/workflows/ci.yml@refs/heads/main").
+func workflowFile() string {
+	ref := os.Getenv("GITHUB_WORKFLOW_REF")
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.Index(ref, "/.github/"); i >= 0 {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+// findLine returns the 1-based number of the first line of the file that
+// contains one of keys, or 1.
+func findLine(path string, keys []string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		for _, key := range keys {
+			if strings.Contains(scanner.Text(), key) {
+				return n
+			}
+		}
+	}
+	return 1
+}
@@ -0,0 +1,199 @@
+package action
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// recordedRequest is a request received by a recordingServer.
+type recordedRequest struct {
+	Method, Path, Auth string
+	Body               []byte
+}
+
+// recordingServer answers every request with status and records it.
+func recordingServer(t *testing.T, status int) (*PRInfo, *[]recordedRequest) {
+	t.Helper()
+	var reqs []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, recordedRequest{r.Method, r.URL.Path, r.Header.Get("Authorization"), body})
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"recorded"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &PRInfo{RepoInfo: RepoInfo{Owner: "o", Repo: "r", HeadSHA: "abc123", Token: "t0ken", APIURL: srv.URL + "/"}, Number: 7}, &reqs
+}
+
+type checkRunPayload struct {
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	Output     struct {
+		Title       string `json:"title"`
+		Summary     string `json:"summary"`
+		Annotations []struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+			Level     string `json:"annotation_level"`
+			Message   string `json:"message"`
+		} `json:"annotations"`
+	} `json:"output"`
+}
+
+func TestCreateCheckRun(t *testing.T) {
+	pr, reqs := recordingServer(t, http.StatusCreated)
+
+	run := CheckRun{
+		Conclusion: ConclusionFailure,
+		Title:      "$1.00 known cost",
+		Summary:    "## report",
+	}
+	for i := 0; i < maxCheckAnnotations+5; i++ {
+		run.Annotations = append(run.Annotations, Annotation{Path: ".github/workflows/ci.yml", Line: 12, Level: "failure", Message: "over"})
+	}
+	if err := CreateCheckRun(pr, run); err != nil {
+		t.Fatalf("CreateCheckRun: %v", err)
+	}
+
+	if len(*reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(*reqs))
+	}
+	req := (*reqs)[0]
+	if req.Method != "POST" || req.Path != "/repos/o/r/check-runs" || req.Auth != "Bearer t0ken" {
+		t.Errorf("request = %s %s (%s), want POST /repos/o/r/check-runs with the token", req.Method, req.Path, req.Auth)
+	}
+	var got checkRunPayload
+	if err := json.Unmarshal(req.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != CheckName || got.HeadSHA != "abc123" || got.Status != "completed" || got.Conclusion != ConclusionFailure {
+		t.Errorf("check run = %+v, want a completed failure on abc123", got)
+	}
+	if got.Output.Title != run.Title || got.Output.Summary != run.Summary {
+		t.Errorf("output = %q / %q, want %q / %q", got.Output.Title, got.Output.Summary, run.Title, run.Summary)
+	}
+	a := got.Output.Annotations
+	if len(a) != maxCheckAnnotations {
+		t.Fatalf("annotations = %d, want %d", len(a), maxCheckAnnotations)
+	}
+	if a[0].Path != ".github/workflows/ci.yml" || a[0].StartLine != 12 || a[0].EndLine != 12 || a[0].Level != "failure" {
+		t.Errorf("annotation = %+v, want a failure on line 12", a[0])
+	}
+}
+
+func TestCreateCheckRunTruncatesSummary(t *testing.T) {
+	pr, reqs := recordingServer(t, http.StatusCreated)
+
+	// Multi-byte runes straddle the cut.
+	run := CheckRun{Conclusion: ConclusionSuccess, Summary: strings.Repeat("€", maxCheckSummary)}
+	if err := CreateCheckRun(pr, run); err != nil {
+		t.Fatalf("CreateCheckRun: %v", err)
+	}
+	var got checkRunPayload
+	if err := json.Unmarshal((*reqs)[0].Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	summary := got.Output.Summary
+	if len(summary) > maxCheckSummary {
+		t.Errorf("summary length = %d, want at most %d", len(summary), maxCheckSummary)
+	}
+	if !utf8.ValidString(summary) || !strings.HasSuffix(summary, "€\n…") {
+		t.Errorf("summary ends with %q, want whole runes and \"\\n…\"", summary[len(summary)-10:])
+	}
+}
+
+func TestCreateCheckRunErrors(t *testing.T) {
+	pr, reqs := recordingServer(t, http.StatusForbidden)
+	err := CreateCheckRun(pr, CheckRun{Conclusion: ConclusionSuccess})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("CreateCheckRun() error = %v, want the 403", err)
+	}
+
+	pr.HeadSHA = ""
+	if err := CreateCheckRun(pr, CheckRun{}); err == nil {
+		t.Error("CreateCheckRun() without a head commit succeeded")
+	}
+	if len(*reqs) != 1 {
+		t.Errorf("requests = %d, want 1", len(*reqs))
+	}
+}
+
+func TestWorkflowAnnotation(t *testing.T) {
+	dir := t.TempDir()
+	workflow := "name: ci\non: pull_request\njobs:\n  cost:\n    steps:\n      - uses: plarix/scan@v1\n        with:\n          fail_on_cost_usd: 1\n"
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_WORKSPACE", dir)
+
+	tests := []struct {
+		name     string
+		ref      string
+		keys     []string
+		wantOK   bool
+		wantPath string
+		wantLine int
+	}{
+		{"input", "o/r/.github/workflows/ci.yml@refs/pull/7/merge", []string{"fail_on_cost_usd", "--fail-on-cost"}, true, ".github/workflows/ci.yml", 8},
+		{"flag", "o/r/.github/workflows/ci.yml@refs/heads/main", []string{"--model-cap", "uses:"}, true, ".github/workflows/ci.yml", 6},
+		{"no match", "o/r/.github/workflows/ci.yml@refs/heads/main", []string{"model_caps"}, true, ".github/workflows/ci.yml", 1},
+		{"missing file", "o/r/.github/workflows/other.yml@refs/heads/main", []string{"fail_on_cost_usd"}, true, ".github/workflows/other.yml", 1},
+		{"outside actions", "", []string{"fail_on_cost_usd"}, false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_WORKFLOW_REF", tt.ref)
+			a, ok := WorkflowAnnotation("Budget exceeded", "over", tt.keys...)
+			if ok != tt.wantOK || a.Path != tt.wantPath || a.Line != tt.wantLine {
+				t.Errorf("WorkflowAnnotation() = %s:%d, %v, want %s:%d, %v", a.Path, a.Line, ok, tt.wantPath, tt.wantLine, tt.wantOK)
+			}
+			if ok && (a.Level != "failure" || a.Title != "Budget exceeded" || a.Message != "over") {
+				t.Errorf("annotation = %+v", a)
+			}
+		})
+	}
+}
+
+func TestGetPRNumber(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      string
+		refName    string
+		wantNumber int
+		wantSHA    string
+	}{
+		{"pull request", `{"number":7,"pull_request":{"number":7,"head":{"sha":"abc123"}}}`, "7/merge", 7, "abc123"},
+		{"merge ref only", `{}`, "12/merge", 12, ""},
+		{"ref with event head", `{"pull_request":{"number":9,"head":{"sha":"def456"}}}`, "9/merge", 9, "def456"},
+		{"issue comment", `{"issue":{"number":5}}`, "main", 5, ""},
+		{"push", `{"ref":"refs/heads/main","after":"abc123"}`, "main", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "event.json")
+			if err := os.WriteFile(path, []byte(tt.event), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("GITHUB_EVENT_PATH", path)
+			t.Setenv("GITHUB_REF_NAME", tt.refName)
+
+			number, sha := getPRNumber()
+			if number != tt.wantNumber || sha != tt.wantSHA {
+				t.Errorf("getPRNumber() = %d, %q, want %d, %q", number, sha, tt.wantNumber, tt.wantSHA)
+			}
+		})
+	}
+}
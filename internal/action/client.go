@@ -0,0 +1,191 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry policy of the GitHub client. Requests are retried on 5xx responses
+// and rate limits, waiting retryBase, then twice as long for each further
+// attempt, or as long as GitHub asks (Retry-After, X-RateLimit-Reset) up to
+// maxRetryWait.
+const (
+	requestTimeout = 30 * time.Second
+	maxRetries     = 4
+	maxRetryWait   = time.Minute
+)
+
+var (
+	retryBase = time.Second
+	sleep     = time.Sleep // replaced in tests
+)
+
+// client calls a REST API (GitHub's, or a platform with the same
+// conventions) with fixed authentication headers.
+type client struct {
+	http   *http.Client
+	apiURL string
+	header http.Header // sent with every request
+}
+
+// newClient returns a GitHub API client for the repository's token.
+func newClient(repo *RepoInfo) *client {
+	return &client{
+		http:   &http.Client{Timeout: requestTimeout},
+		apiURL: strings.TrimSuffix(repo.APIURL, "/"),
+		header: http.Header{
+			"Authorization":        {"Bearer " + repo.Token},
+			"Accept":               {"application/vnd.github+json"},
+			"X-Github-Api-Version": {"2022-11-28"},
+		},
+	}
+}
+
+// apiError is a GitHub API response with an unexpected status.
+type apiError struct {
+	Status string
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s - %s", e.Status, e.Body)
+}
+
+// do sends a request to path (relative to the API URL, or an absolute URL)
+// with in as the JSON body, and decodes the JSON response into out, either
+// of which may be nil. A []byte in is sent as is, already encoded. It
+// returns the response headers.
+func (c *client) do(method, path string, in, out interface{}) (http.Header, error) {
+	url := path
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = c.apiURL + path
+	}
+	var body []byte
+	switch in := in.(type) {
+	case nil:
+	case []byte:
+		body = in
+	default:
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.header {
+			req.Header[k] = v
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			// The request may have reached GitHub; only repeat it if
+			// that is harmless.
+			if attempt < maxRetries && method != "POST" {
+				sleep(backoff(attempt))
+				continue
+			}
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if out != nil && len(respBody) > 0 {
+				if err := json.Unmarshal(respBody, out); err != nil {
+					return nil, fmt.Errorf("decode %s %s: %w", method, path, err)
+				}
+			}
+			return resp.Header, nil
+		}
+
+		if wait, ok := retryAfter(resp, respBody, attempt); ok && attempt < maxRetries {
+			sleep(wait)
+			continue
+		}
+		return nil, &apiError{Status: resp.Status, Body: string(respBody)}
+	}
+}
+
+// retryAfter reports whether a failed response is worth retrying, and how
+// long to wait first: server errors and primary or secondary rate limits.
+func retryAfter(resp *http.Response, body []byte, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode >= 500:
+		return backoff(attempt), true
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusForbidden:
+	default:
+		return 0, false
+	}
+
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return capWait(time.Duration(secs) * time.Second)
+		}
+	}
+	// GitHub's X-RateLimit-* headers, or GitLab's RateLimit-*.
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		if reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64); err == nil {
+			return capWait(time.Until(time.Unix(reset, 0)))
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")) {
+		return backoff(attempt), true
+	}
+	return 0, false // a plain 403: missing permission
+}
+
+// backoff is the wait before retry number attempt+1.
+func backoff(attempt int) time.Duration {
+	return retryBase << uint(attempt)
+}
+
+// capWait accepts waits up to maxRetryWait; longer ones are not retried.
+func capWait(d time.Duration) (time.Duration, bool) {
+	if d < 0 {
+		d = 0
+	}
+	return d, d <= maxRetryWait
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// list fetches every page of a list endpoint, following the Link header,
+// and calls page with each page's JSON array until it returns true.
+func (c *client) list(path string, page func(items json.RawMessage) (stop bool, err error)) error {
+	for path != "" {
+		var items json.RawMessage
+		header, err := c.do("GET", path, nil, &items)
+		if err != nil {
+			return err
+		}
+		if stop, err := page(items); stop || err != nil {
+			return err
+		}
+		path = ""
+		if m := linkNext.FindStringSubmatch(header.Get("Link")); m != nil {
+			path = m[1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub stands in for the parts of the GitHub API the action uses.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	comments []fakeComment
+	nextID   int64
+	perPage  int
+	fail     []int // statuses to answer with before serving, in order
+	header   http.Header
+	requests []string // "METHOD path?query"
+}
+
+type fakeComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *PRInfo) {
+	t.Helper()
+	gh := &fakeGitHub{nextID: 1000, perPage: 30}
+	srv := httptest.NewServer(gh)
+	t.Cleanup(srv.Close)
+
+	old := sleep
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = old })
+
+	return gh, &PRInfo{RepoInfo: RepoInfo{Owner: "o", Repo: "r", HeadSHA: "abc123", Token: "t0ken", APIURL: srv.URL}, Number: 7}
+}
+
+func (gh *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.requests = append(gh.requests, r.Method+" "+r.URL.RequestURI())
+
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	if len(gh.fail) > 0 {
+		for k, v := range gh.header {
+			w.Header()[k] = v
+		}
+		status := gh.fail[0]
+		gh.fail = gh.fail[1:]
+		msg := "error"
+		if status == http.StatusForbidden && gh.header == nil {
+			msg = "You have exceeded a secondary rate limit"
+		}
+		http.Error(w, fmt.Sprintf(`{"message":%q}`, msg), status)
+		return
+	}
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	data, _ := io.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/repos/o/r/issues/7/comments":
+		perPage := gh.perPage
+		if n, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && n < perPage {
+			perPage = n
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(gh.comments) {
+			start = len(gh.comments)
+		}
+		if end >= len(gh.comments) {
+			end = len(gh.comments)
+		} else {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next", <http://%s/last>; rel="last"`, r.Host, next.RequestURI(), r.Host))
+		}
+		json.NewEncoder(w).Encode(gh.comments[start:end])
+	case r.Method == "POST" && r.URL.Path == "/repos/o/r/issues/7/comments":
+		gh.nextID++
+		gh.comments = append(gh.comments, fakeComment{gh.nextID, body.Body})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(gh.comments[len(gh.comments)-1])
+	case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, "/repos/o/r/issues/comments/"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/repos/o/r/issues/comments/"), 10, 64)
+		for i := range gh.comments {
+			if gh.comments[i].ID == id {
+				gh.comments[i].Body = body.Body
+				json.NewEncoder(w).Encode(gh.comments[i])
+				return
+			}
+		}
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	case r.Method == "POST" && r.URL.Path == "/repos/o/r/statuses/abc123":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPostCommentFindsMarkerOnLaterPage(t *testing.T) {
+	gh, pr := newFakeGitHub(t)
+	for i := 0; i < 250; i++ {
+		gh.comments = append(gh.comments, fakeComment{int64(i + 1), fmt.Sprintf("comment %d", i)})
+	}
+	gh.comments[180].Body = commentMarker + "\nold report"
+
+	for run := 0; run < 2; run++ {
+		if err := PostComment(pr, "new report"); err != nil {
+			t.Fatalf("PostComment: %v", err)
+		}
+	}
+	if len(gh.comments) != 250 {
+		t.Errorf("comments = %d, want 250 (no duplicate)", len(gh.comments))
+	}
+	if got, want := gh.comments[180].Body, commentMarker+"\nnew report"; got != want {
+		t.Errorf("marked comment = %q, want %q", got, want)
+	}
+}
+
+func TestPostCommentCreatesOnce(t *testing.T) {
+	gh, pr := newFakeGitHub(t)
+	gh.perPage = 2
+	gh.comments = []fakeComment{{1, "a"}, {2, "b"}, {3, "c"}}
+
+	for run := 0; run < 3; run++ {
+		if err := PostComment(pr, fmt.Sprintf("report %d", run)); err != nil {
+			t.Fatalf("PostComment: %v", err)
+		}
+	}
+	if len(gh.comments) != 4 {
+		t.Fatalf("comments = %d, want 4", len(gh.comments))
+	}
+	if got := gh.comments[3].Body; got != commentMarker+"\nreport 2" {
+		t.Errorf("comment = %q, want the last report", got)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)
+	tests := []struct {
+		name     string
+		fail     []int
+		header   http.Header
+		wantErr  bool
+		attempts int
+	}{
+		{"server error", []int{502, 503}, nil, false, 3},
+		{"secondary rate limit", []int{403}, nil, false, 2},
+		{"retry after", []int{429}, http.Header{"Retry-After": {"3"}}, false, 2},
+		{"rate limit reset", []int{403}, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {reset}}, false, 2},
+		{"reset too far", []int{403}, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"99999999999"}}, true, 1},
+		{"forbidden", []int{403}, http.Header{}, true, 1},
+		{"not found", []int{404}, nil, true, 1},
+		{"gives up", []int{500, 500, 500, 500, 500, 500}, nil, true, maxRetries + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, pr := newFakeGitHub(t)
+			gh.fail, gh.header = tt.fail, tt.header
+
+			err := SetCommitStatus(&pr.RepoInfo, StatusSuccess, "LLM cost: $0.42")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetCommitStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(gh.requests) != tt.attempts {
+				t.Errorf("attempts = %d, want %d: %v", len(gh.requests), tt.attempts, gh.requests)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var waits []time.Duration
+	old := sleep
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = old }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := newClient(&RepoInfo{APIURL: srv.URL})
+	if _, err := c.do("GET", "/x", nil, nil); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("do() error = %v, want the 503", err)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	if fmt.Sprint(waits) != fmt.Sprint(want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+}
+
+func TestClientSendsEncodedBody(t *testing.T) {
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// Re-encoding would compact this body and change a signature over it.
+	body := []byte("{\n  \"passed\": true,\n  \"note\": \"<b>\"\n}\n")
+	if _, err := newClient(&RepoInfo{APIURL: srv.URL}).do("POST", "/hook", body, nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
@@ -0,0 +1,93 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gitlab reports to GitLab CI: notes on merge requests, and the report as
+// a job artifact.
+type gitlab struct {
+	apiURL    string // CI_API_V4_URL
+	projectID string // CI_PROJECT_ID
+	mrIID     string // CI_MERGE_REQUEST_IID; empty outside merge request pipelines
+	token     string // GITLAB_TOKEN: a token with the api scope
+	dir       string // CI_PROJECT_DIR
+}
+
+func detectGitLab() *gitlab {
+	g := &gitlab{
+		apiURL:    os.Getenv("CI_API_V4_URL"),
+		projectID: os.Getenv("CI_PROJECT_ID"),
+		mrIID:     os.Getenv("CI_MERGE_REQUEST_IID"),
+		token:     os.Getenv("GITLAB_TOKEN"),
+		dir:       os.Getenv("CI_PROJECT_DIR"),
+	}
+	if g.apiURL == "" {
+		g.apiURL = "https://gitlab.com/api/v4"
+	}
+	return g
+}
+
+func (*gitlab) Name() string { return "GitLab" }
+
+func (*gitlab) ChangeRequest() string { return "merge request" }
+
+func (g *gitlab) InChangeRequest() bool { return g.projectID != "" && g.mrIID != "" }
+
+// PostComment creates or updates the merge request note with the marker.
+// CI_JOB_TOKEN cannot write notes, so it needs GITLAB_TOKEN.
+func (g *gitlab) PostComment(content string) error {
+	if g.token == "" {
+		return fmt.Errorf("GITLAB_TOKEN is not set (a token with the api scope is needed to post merge request notes)")
+	}
+	content = commentMarker + "\n" + content
+	c := &client{
+		http:   &http.Client{Timeout: requestTimeout},
+		apiURL: strings.TrimSuffix(g.apiURL, "/"),
+		header: http.Header{"Private-Token": {g.token}},
+	}
+	notes := fmt.Sprintf("/projects/%s/merge_requests/%s/notes", url.PathEscape(g.projectID), url.PathEscape(g.mrIID))
+
+	var existingID int64
+	err := c.list(notes+"?per_page=100", func(page json.RawMessage) (bool, error) {
+		var items []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal(page, &items); err != nil {
+			return false, err
+		}
+		for _, n := range items {
+			if markerRegex.MatchString(n.Body) {
+				existingID = n.ID
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("find existing note: list notes failed: %w", err)
+	}
+
+	body := map[string]string{"body": content}
+	if existingID > 0 {
+		if _, err := c.do("PUT", fmt.Sprintf("%s/%d", notes, existingID), body, nil); err != nil {
+			return fmt.Errorf("update note failed: %w", err)
+		}
+		return nil
+	}
+	if _, err := c.do("POST", notes, body, nil); err != nil {
+		return fmt.Errorf("create note failed: %w", err)
+	}
+	return nil
+}
+
+// WriteSummary writes the report to ReportFile in the project directory.
+func (g *gitlab) WriteSummary(content string) error {
+	return writeReportFile(g.dir, content)
+}
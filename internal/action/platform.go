@@ -0,0 +1,70 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReportFile is the job artifact the report is written to on platforms
+// without a job summary (GitLab, Bitbucket); list it under the job's
+// artifacts to keep it.
+const ReportFile = "plarix-report.md"
+
+// Platform is the CI system a run reports to.
+type Platform interface {
+	// Name is the platform's name for messages, e.g. "GitHub".
+	Name() string
+
+	// ChangeRequest is the platform's term for what PostComment comments
+	// on: "pull request" or "merge request".
+	ChangeRequest() string
+
+	// InChangeRequest reports whether the build is for a pull or merge
+	// request that PostComment can comment on.
+	InChangeRequest() bool
+
+	// PostComment creates or updates the marker-tagged report comment on
+	// the change request.
+	PostComment(content string) error
+
+	// WriteSummary publishes the report with the build: the GitHub step
+	// summary, or a job artifact.
+	WriteSummary(content string) error
+}
+
+// Detect returns the platform of the CI environment: GitLab when
+// GITLAB_CI is set, Bitbucket Pipelines when BITBUCKET_BUILD_NUMBER is,
+// otherwise GitHub (which does nothing outside Actions).
+func Detect() Platform {
+	switch {
+	case os.Getenv("GITLAB_CI") == "true":
+		return detectGitLab()
+	case os.Getenv("BITBUCKET_BUILD_NUMBER") != "":
+		return detectBitbucket()
+	}
+	return github{pr: GetPRInfo()}
+}
+
+// github reports to GitHub Actions.
+type github struct {
+	pr *PRInfo // nil outside a PR
+}
+
+func (github) Name() string { return "GitHub" }
+
+func (github) ChangeRequest() string { return "pull request" }
+
+func (g github) InChangeRequest() bool { return g.pr != nil }
+
+func (g github) PostComment(content string) error { return PostComment(g.pr, content) }
+
+func (github) WriteSummary(content string) error { return WriteStepSummary(content) }
+
+// writeReportFile writes the report to ReportFile in dir.
+func writeReportFile(dir, content string) error {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(filepath.Join(dir, ReportFile), []byte(content), 0644)
+}
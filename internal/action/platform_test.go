@@ -0,0 +1,258 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// commentsAPI describes the comments API of a GitLab merge request or a
+// Bitbucket pull request.
+type commentsAPI struct {
+	path         string // escaped path of the comment list
+	header, auth string // expected authentication
+	bitbucket    bool   // Bitbucket's JSON and "next" links, else GitLab's
+}
+
+// fakeComments stands in for a commentsAPI, listing two comments per page.
+type fakeComments struct {
+	commentsAPI
+	mu       sync.Mutex
+	comments []fakeComment
+}
+
+// bitbucketComment is a comment in Bitbucket's JSON.
+type bitbucketComment struct {
+	ID      int64 `json:"id"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+}
+
+func (f *fakeComments) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get(f.header) != f.auth {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	var body string
+	if f.bitbucket {
+		var c bitbucketComment
+		json.Unmarshal(data, &c)
+		body = c.Content.Raw
+	} else {
+		var n fakeComment
+		json.Unmarshal(data, &n)
+		body = n.Body
+	}
+
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == "GET" && path == f.path:
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		start, end := (page-1)*2, page*2
+		if start > len(f.comments) {
+			start = len(f.comments)
+		}
+		next := ""
+		if end >= len(f.comments) {
+			end = len(f.comments)
+		} else {
+			next = fmt.Sprintf("http://%s%s?page=%d", r.Host, f.path, page+1)
+		}
+		if !f.bitbucket {
+			if next != "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+			}
+			json.NewEncoder(w).Encode(f.comments[start:end])
+			return
+		}
+		resp := struct {
+			Values []bitbucketComment `json:"values"`
+			Next   string             `json:"next,omitempty"`
+		}{Values: []bitbucketComment{}, Next: next}
+		for _, c := range f.comments[start:end] {
+			var bc bitbucketComment
+			bc.ID, bc.Content.Raw = c.ID, c.Body
+			resp.Values = append(resp.Values, bc)
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == "POST" && path == f.path:
+		f.comments = append(f.comments, fakeComment{int64(len(f.comments) + 1), body})
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	case r.Method == "PUT" && strings.HasPrefix(path, f.path+"/"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(path, f.path+"/"), 10, 64)
+		for i := range f.comments {
+			if f.comments[i].ID == id {
+				f.comments[i].Body = body
+				w.Write([]byte(`{}`))
+				return
+			}
+		}
+		http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// platformTests are the platforms that comment through their own API.
+var platformTests = []struct {
+	name string
+	api  commentsAPI
+	// platform returns the platform reporting to apiURL, with its
+	// credentials unless noAuth, and its artifacts in dir.
+	platform func(apiURL, dir string, noAuth bool) Platform
+	wantErr  string // error without credentials
+}{
+	{
+		name: "gitlab",
+		api:  commentsAPI{path: "/api/v4/projects/group%2Fproj/merge_requests/12/notes", header: "Private-Token", auth: "glpat"},
+		platform: func(apiURL, dir string, noAuth bool) Platform {
+			g := &gitlab{apiURL: apiURL + "/api/v4", projectID: "group/proj", mrIID: "12", token: "glpat", dir: dir}
+			if noAuth {
+				g.token = ""
+			}
+			return g
+		},
+		wantErr: "GITLAB_TOKEN",
+	},
+	{
+		name: "bitbucket access token",
+		api:  commentsAPI{path: "/2.0/repositories/team/app/pullrequests/4/comments", header: "Authorization", auth: "Bearer bbtok", bitbucket: true},
+		platform: func(apiURL, dir string, noAuth bool) Platform {
+			b := &bitbucket{apiURL: apiURL + "/2.0", workspace: "team", repoSlug: "app", prID: "4", token: "bbtok", dir: dir}
+			if noAuth {
+				b.token = ""
+			}
+			return b
+		},
+		wantErr: "BITBUCKET_TOKEN",
+	},
+	{
+		name: "bitbucket app password",
+		api:  commentsAPI{path: "/2.0/repositories/team/app/pullrequests/4/comments", header: "Authorization", auth: "Basic Y2k6cHc=", bitbucket: true},
+		platform: func(apiURL, dir string, noAuth bool) Platform {
+			b := &bitbucket{apiURL: apiURL + "/2.0", workspace: "team", repoSlug: "app", prID: "4", username: "ci", password: "pw", dir: dir}
+			if noAuth {
+				b.password = ""
+			}
+			return b
+		},
+		wantErr: "BITBUCKET_TOKEN",
+	},
+	{
+		name: "bitbucket escaped path",
+		api:  commentsAPI{path: "/2.0/repositories/%7B5f6e%7D/app%231/pullrequests/4/comments", header: "Authorization", auth: "Bearer bbtok", bitbucket: true},
+		platform: func(apiURL, dir string, noAuth bool) Platform {
+			b := &bitbucket{apiURL: apiURL + "/2.0", workspace: "{5f6e}", repoSlug: "app#1", prID: "4", token: "bbtok", dir: dir}
+			if noAuth {
+				b.token = ""
+			}
+			return b
+		},
+		wantErr: "BITBUCKET_TOKEN",
+	},
+}
+
+func TestPlatformPostComment(t *testing.T) {
+	for _, tt := range platformTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeComments{commentsAPI: tt.api, comments: []fakeComment{{1, "lgtm"}, {2, "nit"}, {3, "rebase?"}}}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			p := tt.platform(srv.URL, t.TempDir(), false)
+			if !p.InChangeRequest() {
+				t.Fatalf("%s: InChangeRequest() = false", p.Name())
+			}
+			for run := 0; run < 3; run++ {
+				if err := p.PostComment(fmt.Sprintf("report %d", run)); err != nil {
+					t.Fatalf("PostComment: %v", err)
+				}
+			}
+			if len(fake.comments) != 4 {
+				t.Fatalf("comments = %d, want 4 (one created, then updated)", len(fake.comments))
+			}
+			if got, want := fake.comments[3].Body, commentMarker+"\nreport 2"; got != want {
+				t.Errorf("comment = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPlatformWithoutCredentials(t *testing.T) {
+	for _, tt := range platformTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.platform("http://127.0.0.1:1", t.TempDir(), true)
+			if err := p.PostComment("report"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PostComment() error = %v, want it to ask for %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlatformWriteSummary(t *testing.T) {
+	for _, tt := range platformTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := tt.platform("http://127.0.0.1:1", dir, false).WriteSummary("## Report"); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, ReportFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "## Report\n" {
+				t.Errorf("artifact = %q, want the report", data)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		platform  string
+		request   string
+		inRequest bool
+	}{
+		{"local", map[string]string{}, "GitHub", "pull request", false},
+		{"github push", map[string]string{"GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "o/r", "GITHUB_SHA": "abc"}, "GitHub", "pull request", false},
+		{"github pr", map[string]string{"GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "o/r", "GITHUB_REF_NAME": "5/merge"}, "GitHub", "pull request", true},
+		{"gitlab branch", map[string]string{"GITLAB_CI": "true", "CI_PROJECT_ID": "9"}, "GitLab", "merge request", false},
+		{"gitlab mr", map[string]string{"GITLAB_CI": "true", "CI_PROJECT_ID": "9", "CI_MERGE_REQUEST_IID": "3"}, "GitLab", "merge request", true},
+		{"bitbucket branch", map[string]string{"BITBUCKET_BUILD_NUMBER": "1", "BITBUCKET_WORKSPACE": "w", "BITBUCKET_REPO_SLUG": "r"}, "Bitbucket", "pull request", false},
+		{"bitbucket pr", map[string]string{"BITBUCKET_BUILD_NUMBER": "1", "BITBUCKET_WORKSPACE": "w", "BITBUCKET_REPO_SLUG": "r", "BITBUCKET_PR_ID": "2"}, "Bitbucket", "pull request", true},
+	}
+	vars := []string{"GITLAB_CI", "CI_PROJECT_ID", "CI_MERGE_REQUEST_IID", "BITBUCKET_BUILD_NUMBER", "BITBUCKET_WORKSPACE", "BITBUCKET_REPO_SLUG", "BITBUCKET_PR_ID", "GITHUB_TOKEN", "GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_REF_NAME", "GITHUB_EVENT_PATH"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range vars {
+				t.Setenv(v, tt.env[v])
+			}
+			p := Detect()
+			if p.Name() != tt.platform || p.InChangeRequest() != tt.inRequest {
+				t.Errorf("Detect() = %s, in change request %v; want %s, %v", p.Name(), p.InChangeRequest(), tt.platform, tt.inRequest)
+			}
+			if p.ChangeRequest() != tt.request {
+				t.Errorf("ChangeRequest() = %q, want %q", p.ChangeRequest(), tt.request)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package action
+
+import (
+	"fmt"
+	"os"
+)
+
+// Commit status states.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// maxStatusDescription is the longest description the statuses API accepts.
+const maxStatusDescription = 140
+
+// SetCommitStatus sets a CheckName commit status on repo.HeadSHA, linking to
+// the workflow run. Unlike a PR comment or check run it needs no PR, so it
+// also reports pushes to the default branch. The token needs the
+// statuses: write permission.
+func SetCommitStatus(repo *RepoInfo, state, description string) error {
+	if repo.HeadSHA == "" {
+		return fmt.Errorf("set commit status: no commit")
+	}
+	if r := []rune(description); len(r) > maxStatusDescription {
+		description = string(r[:maxStatusDescription-1]) + "…"
+	}
+
+	payload := map[string]string{
+		"state":       state,
+		"description": description,
+		"context":     CheckName,
+	}
+	if url := runURL(); url != "" {
+		payload["target_url"] = url
+	}
+	path := fmt.Sprintf("/repos/%s/%s/statuses/%s", repo.Owner, repo.Repo, repo.HeadSHA)
+	if _, err := newClient(repo).do("POST", path, payload, nil); err != nil {
+		return fmt.Errorf("set commit status failed: %w", err)
+	}
+	return nil
+}
+
+// runURL returns the URL of the running workflow run, or "".
+func runURL() string {
+	server, repo, id := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, id)
+}
@@ -0,0 +1,117 @@
+package action
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetCommitStatus(t *testing.T) {
+	pr, reqs := recordingServer(t, http.StatusCreated)
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_REPOSITORY", "o/r")
+	t.Setenv("GITHUB_RUN_ID", "42")
+
+	if err := SetCommitStatus(&pr.RepoInfo, StatusFailure, "LLM cost: $1.00 known, 3 calls"); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+	req := (*reqs)[0]
+	if req.Method != "POST" || req.Path != "/repos/o/r/statuses/abc123" {
+		t.Errorf("request = %s %s, want POST /repos/o/r/statuses/abc123", req.Method, req.Path)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(req.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"state":       StatusFailure,
+		"description": "LLM cost: $1.00 known, 3 calls",
+		"context":     CheckName,
+		"target_url":  "https://github.com/o/r/actions/runs/42",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	t.Setenv("GITHUB_RUN_ID", "")
+	if err := SetCommitStatus(&pr.RepoInfo, StatusSuccess, "ok"); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+	got = nil
+	json.Unmarshal((*reqs)[1].Body, &got)
+	if _, ok := got["target_url"]; ok {
+		t.Errorf("target_url = %q outside a workflow run, want none", got["target_url"])
+	}
+}
+
+func TestSetCommitStatusTruncatesDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{"fits", strings.Repeat("€", maxStatusDescription), strings.Repeat("€", maxStatusDescription)},
+		{"too long", strings.Repeat("€", maxStatusDescription+1), strings.Repeat("€", maxStatusDescription-1) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, reqs := recordingServer(t, http.StatusCreated)
+			if err := SetCommitStatus(&pr.RepoInfo, StatusSuccess, tt.description); err != nil {
+				t.Fatalf("SetCommitStatus: %v", err)
+			}
+			var got map[string]string
+			if err := json.Unmarshal((*reqs)[0].Body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got["description"] != tt.want {
+				t.Errorf("description = %q (%d runes), want %q", got["description"], len([]rune(got["description"])), tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCommitStatusErrors(t *testing.T) {
+	pr, _ := recordingServer(t, http.StatusNotFound)
+	if err := SetCommitStatus(&pr.RepoInfo, StatusSuccess, "ok"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("SetCommitStatus() error = %v, want the 404", err)
+	}
+}
+
+func TestGetRepoInfoOnPush(t *testing.T) {
+	event := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"ref":"refs/heads/main","after":"abc123"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_TOKEN", "t0ken")
+	t.Setenv("GITHUB_REPOSITORY", "o/r")
+	t.Setenv("GITHUB_EVENT_PATH", event)
+	t.Setenv("GITHUB_REF_NAME", "main")
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("GITHUB_API_URL", "")
+
+	repo := GetRepoInfo()
+	if repo == nil {
+		t.Fatal("GetRepoInfo() = nil on a push")
+	}
+	if repo.Owner != "o" || repo.Repo != "r" || repo.HeadSHA != "abc123" || repo.APIURL != "https://api.github.com" {
+		t.Errorf("GetRepoInfo() = %+v", repo)
+	}
+	if pr := GetPRInfo(); pr != nil {
+		t.Errorf("GetPRInfo() = %+v on a push, want nil", pr)
+	}
+
+	t.Setenv("GITHUB_SHA", "")
+	if repo := GetRepoInfo(); repo != nil {
+		t.Errorf("GetRepoInfo() = %+v without a commit, want nil", repo)
+	}
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("GITHUB_TOKEN", "")
+	if repo := GetRepoInfo(); repo != nil {
+		t.Errorf("GetRepoInfo() = %+v without a token, want nil", repo)
+	}
+}
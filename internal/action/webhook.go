@@ -0,0 +1,40 @@
+package action
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of a webhook body,
+// "sha256=<hex>", keyed by $PLARIX_WEBHOOK_SECRET.
+const WebhookSignatureHeader = "X-Plarix-Signature-256"
+
+// PostWebhook POSTs payload as JSON to url, for CI systems without a
+// built-in platform (Jenkins, Buildkite, ...). Server errors and rate
+// limits are retried like API calls. With $PLARIX_WEBHOOK_SECRET set, the
+// body is signed in WebhookSignatureHeader; the signed bytes are sent
+// unchanged.
+func PostWebhook(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	c := &client{
+		http:   &http.Client{Timeout: requestTimeout},
+		header: http.Header{"User-Agent": {"plarix-scan"}},
+	}
+	if secret := os.Getenv("PLARIX_WEBHOOK_SECRET"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		c.header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	if _, err := c.do("POST", url, body, nil); err != nil {
+		return fmt.Errorf("post webhook failed: %w", err)
+	}
+	return nil
+}
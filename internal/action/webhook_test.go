@@ -0,0 +1,77 @@
+package action
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostWebhook(t *testing.T) {
+	old := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = old }()
+
+	tests := []struct {
+		name     string
+		secret   string
+		fail     int // 5xx responses before accepting
+		wantErr  bool
+		attempts int
+	}{
+		{"unsigned", "", 0, false, 1},
+		{"signed", "s3cret", 0, false, 1},
+		{"retried", "", 2, false, 3},
+		{"rejected", "", maxRetries + 1, true, maxRetries + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PLARIX_WEBHOOK_SECRET", tt.secret)
+			attempts := 0
+			var body []byte
+			var signature, contentType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= tt.fail {
+					http.Error(w, "bad gateway", http.StatusBadGateway)
+					return
+				}
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(WebhookSignatureHeader)
+				contentType = r.Header.Get("Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			err := PostWebhook(srv.URL+"/hooks/plarix", map[string]interface{}{"markdown": "## Report", "passed": true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PostWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, want := string(body), `{"markdown":"## Report","passed":true}`; got != want {
+				t.Errorf("body = %s, want %s", got, want)
+			}
+			if contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+			want := ""
+			if tt.secret != "" {
+				mac := hmac.New(sha256.New, []byte(tt.secret))
+				mac.Write(body)
+				want = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			}
+			if signature != want {
+				t.Errorf("signature = %q, want %q", signature, want)
+			}
+		})
+	}
+}
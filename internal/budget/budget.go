@@ -0,0 +1,209 @@
+// Package budget checks a run's spending against limits.
+//
+// Purpose: Evaluate budget rules (total cost, per-model caps, unknown-cost
+// policy) against a ledger.Summary and report each rule's outcome, for the
+// CLI's exit status, reports and JUnit XML.
+// Public API: Rules, UnknownPolicy, Result, Check, Err, ParseModelCaps,
+// ParseUnknownPolicy, WriteJUnit; Rules.Warnings flags caps on unused models
+// Usage: results := budget.Check(summary, budget.Rules{MaxCost: 5}); then
+// return budget.Err(results) to fail the run when a rule failed.
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"plarix-action/internal/currency"
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+// Rules are the limits a run is checked against. Amounts are in USD unless
+// InDisplayCurrency is set; the summary's display currency is otherwise
+// only used to show them.
+type Rules struct {
+	MaxCost     float64            // limit on the total known cost; zero disables it
+	ModelCaps   map[string]float64 // limit on each model's known cost; a zero cap allows none
+	UnknownCost *UnknownPolicy     // limit on calls with unknown cost; nil allows any
+
+	// InDisplayCurrency takes MaxCost and ModelCaps in the summary's
+	// display currency, converted to USD at its rate. It has no effect on
+	// a summary without one.
+	InDisplayCurrency bool
+}
+
+// UnknownPolicy limits the calls whose cost is unknown, as a number of calls
+// or as a percentage of all calls.
+type UnknownPolicy struct {
+	Max     float64
+	Percent bool
+}
+
+// Result is the outcome of one rule.
+type Result struct {
+	Rule     string `json:"rule"`     // e.g. "total cost"
+	Limit    string `json:"limit"`    // the limit, formatted
+	Measured string `json:"measured"` // the measured value, formatted
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message,omitempty"` // why the rule failed; empty if it passed
+}
+
+// Check evaluates the enabled rules against s, in a fixed order: total cost,
+// model caps by model name, unknown-cost policy.
+func Check(s ledger.Summary, r Rules) []Result {
+	var results []Result
+	if r.MaxCost > 0 {
+		results = append(results, checkTotal(s, r.limit(s, r.MaxCost)))
+	}
+
+	models := make([]string, 0, len(r.ModelCaps))
+	for model := range r.ModelCaps {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		results = append(results, checkModel(s, model, r.limit(s, r.ModelCaps[model])))
+	}
+
+	if r.UnknownCost != nil {
+		results = append(results, checkUnknown(s, *r.UnknownCost))
+	}
+	return results
+}
+
+// limit converts a limit in the rules' currency to USD.
+func (r Rules) limit(s ledger.Summary, amount float64) money.USD {
+	if r.InDisplayCurrency && s.Currency != nil && s.Currency.PerUSD > 0 {
+		return money.FromFloat(amount / s.Currency.PerUSD)
+	}
+	return money.FromFloat(amount)
+}
+
+func checkTotal(s ledger.Summary, max money.USD) Result {
+	res := checkCost(s, "total cost", s.TotalKnownCostUSD, max)
+	if !res.Passed {
+		res.Message = fmt.Sprintf("cost threshold exceeded: %s > %s", res.Measured, res.Limit)
+	}
+	return res
+}
+
+func checkModel(s ledger.Summary, model string, max money.USD) Result {
+	res := checkCost(s, "model "+model, s.ModelBreakdown[model].KnownCostUSD, max)
+	if !res.Passed {
+		res.Message = fmt.Sprintf("model %s cost cap exceeded: %s > %s", model, res.Measured, res.Limit)
+	}
+	return res
+}
+
+// checkCost compares a USD cost with a USD limit.
+func checkCost(s ledger.Summary, rule string, usd, limit money.USD) Result {
+	return Result{
+		Rule:     rule,
+		Limit:    formatUSD(s, limit),
+		Measured: formatUSD(s, usd),
+		Passed:   usd <= limit,
+	}
+}
+
+// formatUSD formats a USD amount, preceded by its value in the summary's
+// display currency when it has one, e.g. "€4.6000 ($5.0000)".
+func formatUSD(s ledger.Summary, usd money.USD) string {
+	if s.Currency == nil {
+		return "$" + usd.Display()
+	}
+	return fmt.Sprintf("%s ($%s)", currency.Format(s.Currency.Code, s.Currency.FromUSD(usd)), usd.Display())
+}
+
+func checkUnknown(s ledger.Summary, p UnknownPolicy) Result {
+	res := Result{Rule: "unknown cost"}
+	if p.Percent {
+		share := 0.0
+		if s.TotalCalls > 0 {
+			share = 100 * float64(s.UnknownCostCalls) / float64(s.TotalCalls)
+		}
+		res.Limit = fmt.Sprintf("%g%% of calls", p.Max)
+		res.Measured = fmt.Sprintf("%.1f%% of calls (%d of %d)", share, s.UnknownCostCalls, s.TotalCalls)
+		res.Passed = share <= p.Max
+	} else {
+		res.Limit = fmt.Sprintf("%g calls", p.Max)
+		res.Measured = fmt.Sprintf("%d calls", s.UnknownCostCalls)
+		res.Passed = float64(s.UnknownCostCalls) <= p.Max
+	}
+	if !res.Passed {
+		res.Message = fmt.Sprintf("unknown-cost calls exceeded: %s > %s", res.Measured, res.Limit)
+	}
+	return res
+}
+
+// Warnings flags rules that cannot apply to s: model caps on models that
+// made no calls, which are usually misspelled model names.
+func (r Rules) Warnings(s ledger.Summary) []string {
+	var models []string
+	for model := range r.ModelCaps {
+		if _, ok := s.ModelBreakdown[model]; !ok {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+
+	warnings := make([]string, len(models))
+	for i, model := range models {
+		warnings[i] = fmt.Sprintf("Model cap on %q matched no calls; check the model name", model)
+	}
+	return warnings
+}
+
+// ParseModelCaps parses comma-separated model=amount pairs, e.g.
+// "gpt-4o=2,claude-opus-4=5".
+func ParseModelCaps(s string) (map[string]float64, error) {
+	caps := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid model cap %q (want model=amount)", pair)
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(pair[i+1:]), 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("invalid model cap %q (want model=amount)", pair)
+		}
+		caps[strings.TrimSpace(pair[:i])] = amount
+	}
+	return caps, nil
+}
+
+// ParseUnknownPolicy parses an unknown-cost policy: "allow" (or empty) for
+// no limit, "fail" for no unknown-cost calls at all, a number of calls such
+// as "3", or a percentage of all calls such as "5%".
+func ParseUnknownPolicy(s string) (*UnknownPolicy, error) {
+	switch s = strings.TrimSpace(strings.ToLower(s)); s {
+	case "", "allow":
+		return nil, nil
+	case "fail":
+		return &UnknownPolicy{}, nil
+	}
+	p := &UnknownPolicy{Percent: strings.HasSuffix(s, "%")}
+	max, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || max < 0 {
+		return nil, fmt.Errorf("invalid unknown-cost policy %q (want allow, fail, a number of calls or a percentage)", s)
+	}
+	p.Max = max
+	return p, nil
+}
+
+// Err returns an error listing the failed rules, or nil if all passed.
+func Err(results []Result) error {
+	var errs []error
+	for _, r := range results {
+		if !r.Passed {
+			errs = append(errs, errors.New(r.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,217 @@
+package budget
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+func TestCheckTotal(t *testing.T) {
+	usd := ledger.Summary{TotalKnownCostUSD: money.FromFloat(1.5)}
+	eur := usd
+	eur.SetCurrency(ledger.Currency{Code: "EUR", PerUSD: 0.5})
+
+	tests := []struct {
+		name       string
+		s          ledger.Summary
+		max        float64
+		inCurrency bool
+		passed     bool
+		message    string
+	}{
+		{"under", usd, 2, false, true, ""},
+		{"equal", usd, 1.5, false, true, ""},
+		{"over", usd, 1, false, false, "cost threshold exceeded: $1.5000 > $1.0000"},
+		// Limits stay in USD by default; the display currency is only shown.
+		{"currency under", eur, 2, false, true, ""},
+		{"currency over", eur, 1, false, false, "cost threshold exceeded: €0.7500 ($1.5000) > €0.5000 ($1.0000)"},
+		// Or they are in the display currency.
+		{"in currency under", eur, 1, true, true, ""},
+		{"in currency over", eur, 0.5, true, false, "cost threshold exceeded: €0.7500 ($1.5000) > €0.5000 ($1.0000)"},
+		{"in currency without one", usd, 1, true, false, "cost threshold exceeded: $1.5000 > $1.0000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Check(tt.s, Rules{MaxCost: tt.max, InDisplayCurrency: tt.inCurrency})
+			if len(results) != 1 {
+				t.Fatalf("len(results) = %d, want 1", len(results))
+			}
+			r := results[0]
+			if r.Passed != tt.passed {
+				t.Errorf("Passed = %v, want %v", r.Passed, tt.passed)
+			}
+			if r.Message != tt.message {
+				t.Errorf("Message = %q, want %q", r.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestErr(t *testing.T) {
+	if err := Err(Check(ledger.Summary{}, Rules{})); err != nil {
+		t.Errorf("Err(no rules) = %v, want nil", err)
+	}
+	results := []Result{{Rule: "a", Passed: true}, {Rule: "b", Message: "b failed"}}
+	if err := Err(results); err == nil || err.Error() != "b failed" {
+		t.Errorf("Err() = %v, want b failed", err)
+	}
+}
+
+func TestCheckModelCaps(t *testing.T) {
+	s := ledger.Summary{ModelBreakdown: map[string]ledger.ModelStats{
+		"gpt-4o": {Calls: 2, KnownCostUSD: money.FromFloat(3)},
+		"o3":     {Calls: 1, KnownCostUSD: money.FromFloat(0.5)},
+	}}
+	results := Check(s, Rules{ModelCaps: map[string]float64{"o3": 1, "gpt-4o": 2, "unused": 1}})
+
+	want := []struct {
+		rule    string
+		passed  bool
+		message string
+	}{
+		{"model gpt-4o", false, "model gpt-4o cost cap exceeded: $3.0000 > $2.0000"},
+		{"model o3", true, ""},
+		{"model unused", true, ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Rule != w.rule || r.Passed != w.passed || r.Message != w.message {
+			t.Errorf("results[%d] = %+v, want %s passed=%v %q", i, r, w.rule, w.passed, w.message)
+		}
+	}
+
+	warnings := Rules{ModelCaps: map[string]float64{"o3": 1, "unused": 1, "gpt4o": 0}}.Warnings(s)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], `"gpt4o"`) || !strings.Contains(warnings[1], `"unused"`) {
+		t.Errorf("Warnings = %q, want gpt4o and unused", warnings)
+	}
+
+	// A zero cap is enforced: the model may not cost anything.
+	if r := Check(s, Rules{ModelCaps: map[string]float64{"o3": 0}})[0]; r.Passed {
+		t.Errorf("zero cap = %+v, want failed", r)
+	}
+
+	s.SetCurrency(ledger.Currency{Code: "EUR", PerUSD: 0.5})
+	if r := Check(s, Rules{ModelCaps: map[string]float64{"gpt-4o": 2}})[0]; r.Passed || r.Measured != "€1.5000 ($3.0000)" {
+		t.Errorf("EUR cap = %+v, want failed at €1.5000 ($3.0000)", r)
+	}
+	if r := Check(s, Rules{ModelCaps: map[string]float64{"gpt-4o": 2}, InDisplayCurrency: true})[0]; !r.Passed || r.Limit != "€2.0000 ($4.0000)" {
+		t.Errorf("cap of €2 = %+v, want passed with a $4.0000 limit", r)
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	s := ledger.Summary{TotalCalls: 40, UnknownCostCalls: 2}
+	tests := []struct {
+		policy   string
+		passed   bool
+		measured string
+	}{
+		{"fail", false, "2 calls"},
+		{"2", true, "2 calls"},
+		{"5%", true, "5.0% of calls (2 of 40)"},
+		{"4.5%", false, "5.0% of calls (2 of 40)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			p, err := ParseUnknownPolicy(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := Check(s, Rules{UnknownCost: p})[0]
+			if r.Passed != tt.passed || r.Measured != tt.measured {
+				t.Errorf("Check() = %+v, want passed=%v measured %q", r, tt.passed, tt.measured)
+			}
+			if !r.Passed && !strings.HasPrefix(r.Message, "unknown-cost calls exceeded: ") {
+				t.Errorf("Message = %q", r.Message)
+			}
+		})
+	}
+
+	if r := Check(ledger.Summary{}, Rules{UnknownCost: &UnknownPolicy{Percent: true}})[0]; !r.Passed {
+		t.Errorf("0%% policy with no calls = %+v, want passed", r)
+	}
+}
+
+func TestParseModelCaps(t *testing.T) {
+	caps, err := ParseModelCaps("gpt-4o=2, meta-llama/llama-3-70b=0.5,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(caps) != 2 || caps["gpt-4o"] != 2 || caps["meta-llama/llama-3-70b"] != 0.5 {
+		t.Errorf("ParseModelCaps() = %v", caps)
+	}
+	for _, bad := range []string{"gpt-4o", "=2", "gpt-4o=x", "gpt-4o=-1"} {
+		if _, err := ParseModelCaps(bad); err == nil {
+			t.Errorf("ParseModelCaps(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParseUnknownPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *UnknownPolicy
+	}{
+		{"", nil},
+		{"allow", nil},
+		{"FAIL", &UnknownPolicy{}},
+		{"3", &UnknownPolicy{Max: 3}},
+		{"2.5%", &UnknownPolicy{Max: 2.5, Percent: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnknownPolicy(tt.in)
+		if err != nil {
+			t.Errorf("ParseUnknownPolicy(%q) error: %v", tt.in, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseUnknownPolicy(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"sometimes", "-1", "%"} {
+		if _, err := ParseUnknownPolicy(bad); err == nil {
+			t.Errorf("ParseUnknownPolicy(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []Result{
+		{Rule: "total cost", Limit: "$1.0000", Measured: "$1.5000", Message: "cost threshold exceeded: $1.5000 > $1.0000"},
+		{Rule: "model o3", Limit: "$2.0000", Measured: "$0.5000", Passed: true},
+		{Rule: "unknown cost", Limit: "0 calls", Measured: "0 calls", Passed: true},
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<?xml") {
+		t.Errorf("output does not start with an XML header:\n%s", buf.String())
+	}
+
+	var doc junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || len(doc.Suites) != 1 {
+		t.Fatalf("testsuites = %d tests, %d failures, %d suites; want 3, 1, 1", doc.Tests, doc.Failures, len(doc.Suites))
+	}
+	cases := doc.Suites[0].Cases
+	if len(cases) != 3 {
+		t.Fatalf("len(cases) = %d, want 3", len(cases))
+	}
+	f := cases[0].Failure
+	if f == nil || f.Message != results[0].Message || f.Text != "measured $1.5000, limit $1.0000" {
+		t.Errorf("total cost failure = %+v", f)
+	}
+	if cases[1].Failure != nil || cases[1].Name != "model o3" || cases[1].SystemOut != "measured $0.5000, limit $2.0000" {
+		t.Errorf("model o3 case = %+v", cases[1])
+	}
+}
@@ -0,0 +1,69 @@
+package budget
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// JUnit XML, as read by CI test reporters. Each rule is a test case in a
+// single suite; a failed rule is a failed test case.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes results as a JUnit XML report with one test case per
+// rule. Failures carry the rule's message, and every case records the
+// measured value and the limit.
+func WriteJUnit(w io.Writer, results []Result) error {
+	suite := junitSuite{Name: "plarix-scan budgets", Tests: len(results), Time: "0"}
+	for _, r := range results {
+		values := fmt.Sprintf("measured %s, limit %s", r.Measured, r.Limit)
+		c := junitCase{ClassName: "plarix.budget", Name: r.Rule, Time: "0", SystemOut: values}
+		if !r.Passed {
+			c.Failure = &junitFailure{Message: r.Message, Type: "BudgetExceeded", Text: values}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	doc := junitSuites{Name: "plarix-scan", Tests: suite.Tests, Failures: suite.Failures, Suites: []junitSuite{suite}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,110 @@
+// Package currency converts USD costs for display in other currencies.
+//
+// Purpose: Load an exchange-rate file, look up rates, format amounts.
+// Public API: Rates, Load, Rate, StaleWarning, Format
+// Usage: Load rates.json, then multiply USD amounts by Rate(code) at
+// presentation time. Costs are always recorded in USD.
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rates holds exchange rates from USD, as units of each currency per 1 USD.
+// AsOf is the date the rates were taken and is shown next to converted totals.
+//
+// Example file:
+//
+//	{"as_of": "2026-10-01", "base": "USD", "rates": {"EUR": 0.92, "GBP": 0.79}}
+type Rates struct {
+	AsOf  string             `json:"as_of"`
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Load reads and parses an exchange-rate JSON file.
+func Load(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rates file: %w", err)
+	}
+
+	var r Rates
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse rates file: %w", err)
+	}
+
+	if r.Base == "" {
+		r.Base = "USD"
+	}
+	if !strings.EqualFold(r.Base, "USD") {
+		return nil, fmt.Errorf("parse rates file: base must be USD, got %q", r.Base)
+	}
+	if _, err := time.Parse("2006-01-02", r.AsOf); err != nil {
+		return nil, fmt.Errorf("parse rates file: invalid as_of %q", r.AsOf)
+	}
+
+	normalized := make(map[string]float64, len(r.Rates))
+	for code, rate := range r.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("parse rates file: rate for %s must be positive", code)
+		}
+		normalized[strings.ToUpper(code)] = rate
+	}
+	r.Rates = normalized
+
+	return &r, nil
+}
+
+// Rate returns the units of the given currency per 1 USD.
+func (r *Rates) Rate(code string) (float64, error) {
+	code = strings.ToUpper(code)
+	if code == "USD" {
+		return 1, nil
+	}
+	rate, ok := r.Rates[code]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", code)
+	}
+	return rate, nil
+}
+
+// StaleWarning returns a warning message if the rates are older than 31 days.
+func (r *Rates) StaleWarning() string {
+	asOf, err := time.Parse("2006-01-02", r.AsOf)
+	if err != nil || time.Since(asOf) > 31*24*time.Hour {
+		return fmt.Sprintf("Exchange rates may be stale (as_of: %s)", r.AsOf)
+	}
+	return ""
+}
+
+// symbols maps common currency codes to their symbols.
+var symbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+}
+
+// Format renders an amount with the currency symbol, or the code for
+// currencies without a well-known symbol (e.g. "CHF 0.0412"). Like
+// money.USD.Display, it uses four decimals, extended to two significant
+// digits for small non-zero amounts.
+func Format(code string, amount float64) string {
+	decimals := 4
+	for decimals < 12 && amount != 0 && math.Abs(amount) < 10*math.Pow10(-decimals) {
+		decimals++
+	}
+
+	code = strings.ToUpper(code)
+	if sym, ok := symbols[code]; ok {
+		return fmt.Sprintf("%s%.*f", sym, decimals, amount)
+	}
+	return fmt.Sprintf("%s %.*f", code, decimals, amount)
+}
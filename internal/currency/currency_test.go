@@ -0,0 +1,77 @@
+package currency
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rates.json")
+
+	content := `{"as_of": "2026-10-01", "base": "USD", "rates": {"eur": 0.92, "CHF": 0.88}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if rate, err := r.Rate("EUR"); err != nil || rate != 0.92 {
+		t.Errorf("Rate(EUR) = %v, %v; want 0.92", rate, err)
+	}
+	if rate, err := r.Rate("usd"); err != nil || rate != 1 {
+		t.Errorf("Rate(usd) = %v, %v; want 1", rate, err)
+	}
+	if _, err := r.Rate("GBP"); err == nil {
+		t.Error("Expected error for missing rate")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := map[string]string{
+		"non-USD base":  `{"as_of": "2026-10-01", "base": "EUR", "rates": {"USD": 1.08}}`,
+		"bad as_of":     `{"as_of": "October", "rates": {"EUR": 0.92}}`,
+		"negative rate": `{"as_of": "2026-10-01", "rates": {"EUR": -1}}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "rates.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestStaleWarning(t *testing.T) {
+	r := &Rates{AsOf: time.Now().Format("2006-01-02")}
+	if w := r.StaleWarning(); w != "" {
+		t.Errorf("Expected no warning for fresh rates, got %q", w)
+	}
+	r = &Rates{AsOf: "2020-01-01"}
+	if w := r.StaleWarning(); w == "" {
+		t.Error("Expected warning for old rates")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format("EUR", 1.5); got != "€1.5000" {
+		t.Errorf("Format(EUR) = %q", got)
+	}
+	if got := Format("chf", 0.04123); got != "CHF 0.0412" {
+		t.Errorf("Format(CHF) = %q", got)
+	}
+	if got := Format("GBP", 0.0000123); got != "£0.000012" {
+		t.Errorf("Format(GBP, sub-cent) = %q", got)
+	}
+}
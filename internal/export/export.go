@@ -0,0 +1,292 @@
+// Package export flattens ledger entries into columns for spreadsheets and
+// data warehouses.
+//
+// Purpose: Write a ledger as CSV or Parquet with a stable column order.
+// Public API: Table, Column, Kind, Flatten, WriteCSV, WriteParquet
+// Usage: t := Flatten(entries); WriteCSV(w, t) or WriteParquet(w, t).
+//
+// Columns are the Entry fields in a fixed order, followed by one tag.<key>
+// column per tag and one raw_usage.<path> column per provider usage field,
+// each group sorted by name. Nested usage objects are flattened with dots
+// (raw_usage.prompt_tokens_details.cached_tokens).
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+// Kind is the type of a column's values.
+type Kind int
+
+const (
+	KindString    Kind = iota
+	KindInt            // int64
+	KindFloat          // float64
+	KindBool           // bool
+	KindDecimal        // money.USD, exported as DECIMAL(18,12)
+	KindTimestamp      // time.Time, UTC milliseconds
+)
+
+// Column is one exported column. Value returns the cell for a row, or false
+// when the cell is empty (null in Parquet).
+type Column struct {
+	Name  string
+	Kind  Kind
+	Value func(row int) (interface{}, bool)
+}
+
+// Table is a ledger flattened into columns, in export order.
+type Table struct {
+	Columns []Column
+	Rows    int
+}
+
+// fixedColumn describes an Entry field column.
+type fixedColumn struct {
+	name string
+	kind Kind
+	get  func(e *ledger.Entry) (interface{}, bool)
+}
+
+func str(f func(e *ledger.Entry) string) func(e *ledger.Entry) (interface{}, bool) {
+	return func(e *ledger.Entry) (interface{}, bool) { return f(e), true }
+}
+
+func integer(f func(e *ledger.Entry) int64) func(e *ledger.Entry) (interface{}, bool) {
+	return func(e *ledger.Entry) (interface{}, bool) { return f(e), true }
+}
+
+func boolean(f func(e *ledger.Entry) bool) func(e *ledger.Entry) (interface{}, bool) {
+	return func(e *ledger.Entry) (interface{}, bool) { return f(e), true }
+}
+
+// fixedColumns lists the Entry fields in export order. New fields are
+// appended so that existing column positions never move.
+var fixedColumns = []fixedColumn{
+	{"schema_version", KindInt, integer(func(e *ledger.Entry) int64 { return int64(e.SchemaVersion) })},
+	{"ts", KindTimestamp, func(e *ledger.Entry) (interface{}, bool) {
+		t, err := time.Parse(time.RFC3339, e.Timestamp)
+		return t.UTC(), err == nil
+	}},
+	{"provider", KindString, str(func(e *ledger.Entry) string { return e.Provider })},
+	{"endpoint", KindString, str(func(e *ledger.Entry) string { return e.Endpoint })},
+	{"model", KindString, str(func(e *ledger.Entry) string { return e.Model })},
+	{"input_tokens", KindInt, integer(func(e *ledger.Entry) int64 { return int64(e.InputTokens) })},
+	{"output_tokens", KindInt, integer(func(e *ledger.Entry) int64 { return int64(e.OutputTokens) })},
+	{"cost_usd", KindDecimal, func(e *ledger.Entry) (interface{}, bool) {
+		// An unknown cost is empty, not zero.
+		return e.CostUSD, e.CostKnown
+	}},
+	{"cost_known", KindBool, boolean(func(e *ledger.Entry) bool { return e.CostKnown })},
+	{"unknown_reason", KindString, str(func(e *ledger.Entry) string { return e.UnknownReason })},
+	{"cost_source", KindString, str(func(e *ledger.Entry) string { return e.CostSource })},
+	{"pricing_tier", KindString, str(func(e *ledger.Entry) string { return e.PricingTier })},
+	{"service_tier", KindString, str(func(e *ledger.Entry) string { return e.ServiceTier })},
+	{"batch", KindBool, boolean(func(e *ledger.Entry) bool { return e.Batch })},
+	{"request_id", KindString, str(func(e *ledger.Entry) string { return e.RequestID })},
+	{"streaming", KindBool, boolean(func(e *ledger.Entry) bool { return e.Streaming })},
+	{"reported_cost_usd", KindDecimal, func(e *ledger.Entry) (interface{}, bool) {
+		if e.ReportedCostUSD == nil {
+			return nil, false
+		}
+		return *e.ReportedCostUSD, true
+	}},
+	{"upstream_provider", KindString, str(func(e *ledger.Entry) string { return e.UpstreamProvider })},
+	{"cost_mismatch", KindBool, boolean(func(e *ledger.Entry) bool { return e.CostMismatch })},
+	{"computed_cost_usd", KindDecimal, func(e *ledger.Entry) (interface{}, bool) {
+		return e.ComputedCostUSD, e.CostMismatch
+	}},
+	{"duration_ms", KindInt, func(e *ledger.Entry) (interface{}, bool) { return e.DurationMS, e.DurationMS > 0 }},
+	{"ttfb_ms", KindInt, func(e *ledger.Entry) (interface{}, bool) { return e.TTFBMS, e.TTFBMS > 0 }},
+	{"prev_hash", KindString, str(func(e *ledger.Entry) string { return e.PrevHash })},
+}
+
+// Flatten builds the export table for entries.
+func Flatten(entries []ledger.Entry) *Table {
+	t := &Table{Rows: len(entries)}
+
+	for _, c := range fixedColumns {
+		get := c.get
+		t.Columns = append(t.Columns, Column{
+			Name: c.name,
+			Kind: c.kind,
+			Value: func(row int) (interface{}, bool) {
+				return get(&entries[row])
+			},
+		})
+	}
+
+	// Tag columns.
+	tagKeys := make(map[string]bool)
+	for _, e := range entries {
+		for k := range e.Tags {
+			tagKeys[k] = true
+		}
+	}
+	for _, k := range sortedKeys(tagKeys) {
+		key := k
+		t.Columns = append(t.Columns, Column{
+			Name: "tag." + key,
+			Kind: KindString,
+			Value: func(row int) (interface{}, bool) {
+				v, ok := entries[row].Tags[key]
+				return v, ok
+			},
+		})
+	}
+
+	// Raw usage columns, typed by the values seen across all rows.
+	flat := make([]map[string]interface{}, len(entries))
+	kinds := make(map[string]Kind)
+	for i, e := range entries {
+		flat[i] = make(map[string]interface{})
+		flattenUsage("raw_usage", e.RawUsage, flat[i])
+		for k, v := range flat[i] {
+			kind, seen := kinds[k]
+			kinds[k] = mergeKind(kind, seen, valueKind(v))
+		}
+	}
+	names := make(map[string]bool, len(kinds))
+	for k := range kinds {
+		names[k] = true
+	}
+	for _, k := range sortedKeys(names) {
+		key, kind := k, kinds[k]
+		t.Columns = append(t.Columns, Column{
+			Name: key,
+			Kind: kind,
+			Value: func(row int) (interface{}, bool) {
+				v, ok := flat[row][key]
+				if !ok {
+					return nil, false
+				}
+				return convertUsage(v, kind), true
+			},
+		})
+	}
+
+	return t
+}
+
+// flattenUsage copies the leaves of a decoded JSON object into out, keyed
+// by their dotted path. Arrays are kept whole.
+func flattenUsage(prefix string, m map[string]interface{}, out map[string]interface{}) {
+	for k, v := range m {
+		path := prefix + "." + k
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenUsage(path, nested, out)
+			continue
+		}
+		if v != nil {
+			out[path] = v
+		}
+	}
+}
+
+// valueKind is the narrowest column kind holding a decoded JSON value.
+func valueKind(v interface{}) Kind {
+	switch x := v.(type) {
+	case float64:
+		if x == float64(int64(x)) {
+			return KindInt
+		}
+		return KindFloat
+	case bool:
+		return KindBool
+	}
+	return KindString
+}
+
+// mergeKind widens a column kind to also hold next: integers widen to
+// floats, and any other mix falls back to strings.
+func mergeKind(kind Kind, seen bool, next Kind) Kind {
+	switch {
+	case !seen || kind == next:
+		return next
+	case (kind == KindInt && next == KindFloat) || (kind == KindFloat && next == KindInt):
+		return KindFloat
+	}
+	return KindString
+}
+
+// convertUsage converts a decoded JSON value to a cell of the given kind.
+func convertUsage(v interface{}, kind Kind) interface{} {
+	switch kind {
+	case KindInt:
+		return int64(v.(float64))
+	case KindFloat:
+		return v.(float64)
+	case KindBool:
+		return v.(bool)
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteCSV writes t as CSV with a header row. Costs are exact decimals,
+// timestamps RFC 3339, and empty cells are left blank.
+func WriteCSV(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+
+	record := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		record[i] = c.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for row := 0; row < t.Rows; row++ {
+		for i, c := range t.Columns {
+			v, ok := c.Value(row)
+			record[i] = ""
+			if ok {
+				record[i] = formatCell(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatCell renders a cell value for CSV.
+func formatCell(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case money.USD:
+		return x.String()
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+	return ""
+}
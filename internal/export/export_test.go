@@ -0,0 +1,260 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+func testEntries(t *testing.T) []ledger.Entry {
+	t.Helper()
+	lines := []string{
+		`{"schema_version":2,"ts":"2026-10-18T09:00:00Z","provider":"openai","endpoint":"/v1/chat/completions","model":"gpt-4o","input_tokens":1200,"output_tokens":300,"raw_usage":{"prompt_tokens":1200,"completion_tokens":300,"prompt_tokens_details":{"cached_tokens":1024}},"cost_usd":0.006,"cost_known":true,"cost_source":"pricing_table","streaming":false,"tags":{"team":"search"},"duration_ms":850,"ttfb_ms":420}`,
+		`{"schema_version":2,"ts":"2026-10-18T09:05:00Z","provider":"openrouter","endpoint":"/api/v1/chat/completions","model":"meta-llama/llama-3-70b","input_tokens":10,"output_tokens":5,"raw_usage":{"prompt_tokens":10,"completion_tokens":5,"cost":0.0000123},"cost_usd":0.0000123,"cost_known":true,"cost_source":"provider","streaming":true,"reported_cost_usd":0.0000123,"upstream_provider":"Groq","tags":{"env":"prod","team":"ads, \"beta\""}}`,
+		`{"schema_version":2,"ts":"2026-10-18T09:10:00Z","provider":"anthropic","endpoint":"/v1/messages","model":"claude-x","cost_known":false,"unknown_reason":"model not found in pricing table","streaming":false}`,
+	}
+	entries := make([]ledger.Entry, len(lines))
+	for i, l := range lines {
+		if err := json.Unmarshal([]byte(l), &entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return entries
+}
+
+func TestFlattenColumnOrder(t *testing.T) {
+	tbl := Flatten(testEntries(t))
+
+	var names []string
+	for _, c := range tbl.Columns[len(fixedColumns):] {
+		names = append(names, fmt.Sprintf("%s:%d", c.Name, c.Kind))
+	}
+	want := []string{
+		"tag.env:0",
+		"tag.team:0",
+		"raw_usage.completion_tokens:1",
+		"raw_usage.cost:2",
+		"raw_usage.prompt_tokens:1",
+		"raw_usage.prompt_tokens_details.cached_tokens:1",
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("dynamic columns = %v, want %v", names, want)
+	}
+	if tbl.Columns[0].Name != "schema_version" || tbl.Columns[1].Name != "ts" {
+		t.Errorf("fixed columns start with %s, %s", tbl.Columns[0].Name, tbl.Columns[1].Name)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, Flatten(testEntries(t))); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header + 3 rows:\n%s", len(lines), buf.String())
+	}
+
+	wantHeader := "schema_version,ts,provider,endpoint,model,input_tokens,output_tokens,cost_usd,cost_known," +
+		"unknown_reason,cost_source,pricing_tier,service_tier,batch,request_id,streaming,reported_cost_usd," +
+		"upstream_provider,cost_mismatch,computed_cost_usd,duration_ms,ttfb_ms,prev_hash," +
+		"tag.env,tag.team,raw_usage.completion_tokens,raw_usage.cost,raw_usage.prompt_tokens," +
+		"raw_usage.prompt_tokens_details.cached_tokens"
+	if lines[0] != wantHeader {
+		t.Errorf("header =\n%s\nwant\n%s", lines[0], wantHeader)
+	}
+
+	wantRows := []string{
+		"2,2026-10-18T09:00:00Z,openai,/v1/chat/completions,gpt-4o,1200,300,0.006,true,,pricing_table,,,false,,false,,,false,,850,420,,,search,300,,1200,1024",
+		`2,2026-10-18T09:05:00Z,openrouter,/api/v1/chat/completions,meta-llama/llama-3-70b,10,5,0.0000123,true,,provider,,,false,,true,0.0000123,Groq,false,,,,,prod,"ads, ""beta""",5,1.23e-05,10,`,
+		"2,2026-10-18T09:10:00Z,anthropic,/v1/messages,claude-x,0,0,,false,model not found in pricing table,,,,false,,false,,,false,,,,,,,,,,",
+	}
+	for i, want := range wantRows {
+		if lines[i+1] != want {
+			t.Errorf("row %d =\n%s\nwant\n%s", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+	tbl := Flatten(testEntries(t))
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, tbl); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	file := buf.Bytes()
+
+	if string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := readStruct(t, bytes.NewReader(file[len(file)-8-footerLen:len(file)-8]))
+
+	if meta[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(tbl.Columns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(tbl.Columns)+1)
+	}
+	chunks := meta[4].([]interface{})[0].(tstruct)[1].([]interface{})
+
+	for i, c := range tbl.Columns {
+		elem := schema[i+1].(tstruct)
+		if elem[4] != c.Name {
+			t.Errorf("schema[%d] name = %v, want %s", i+1, elem[4], c.Name)
+		}
+		cmeta := chunks[i].(tstruct)[3].(tstruct)
+		offset := cmeta[9].(int64)
+
+		r := bytes.NewReader(file[offset:])
+		header := readStruct(t, r)
+		size := header[3].(int64)
+		body := make([]byte, size)
+		r.Read(body)
+
+		got := decodePage(t, body, elem[1].(int64), tbl.Rows)
+		for row := 0; row < tbl.Rows; row++ {
+			want, ok := c.Value(row)
+			if !ok {
+				want = nil
+			}
+			switch x := want.(type) {
+			case money.USD:
+				want = int64(x)
+			case time.Time:
+				want = x.UnixMilli()
+			}
+			if fmt.Sprint(got[row]) != fmt.Sprint(want) {
+				t.Errorf("%s row %d = %v, want %v", c.Name, row, got[row], want)
+			}
+		}
+	}
+
+	// Spot-check logical types.
+	for i, c := range tbl.Columns {
+		elem := schema[i+1].(tstruct)
+		switch c.Name {
+		case "cost_usd":
+			if elem[6] != int64(convertedDecimal) || elem[7] != int64(12) || elem[8] != int64(18) {
+				t.Errorf("cost_usd schema = %v, want DECIMAL(18,12)", elem)
+			}
+		case "ts":
+			if elem[6] != int64(convertedTimestampMillis) {
+				t.Errorf("ts schema = %v, want TIMESTAMP_MILLIS", elem)
+			}
+		}
+	}
+}
+
+// decodePage decodes a PLAIN data page with RLE definition levels.
+func decodePage(t *testing.T, body []byte, physical int64, rows int) []interface{} {
+	t.Helper()
+	defLen := int(binary.LittleEndian.Uint32(body))
+	defs := bytes.NewReader(body[4 : 4+defLen])
+	var levels []byte
+	for defs.Len() > 0 {
+		h, _ := binary.ReadUvarint(defs)
+		v, _ := defs.ReadByte()
+		if h&1 != 0 {
+			t.Fatal("unexpected bit-packed run")
+		}
+		for i := uint64(0); i < h>>1; i++ {
+			levels = append(levels, v)
+		}
+	}
+	if len(levels) != rows {
+		t.Fatalf("decoded %d levels, want %d", len(levels), rows)
+	}
+
+	values := body[4+defLen:]
+	out := make([]interface{}, rows)
+	bit := 0
+	for row := range out {
+		if levels[row] == 0 {
+			continue
+		}
+		switch physical {
+		case typeInt64:
+			out[row] = int64(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		case typeDouble:
+			out[row] = math.Float64frombits(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		case typeByteArray:
+			n := binary.LittleEndian.Uint32(values)
+			out[row] = string(values[4 : 4+n])
+			values = values[4+n:]
+		case typeBoolean:
+			out[row] = values[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		}
+	}
+	return out
+}
+
+// tstruct is a decoded Thrift struct, keyed by field ID.
+type tstruct map[int16]interface{}
+
+func readStruct(t *testing.T, r *bytes.Reader) tstruct {
+	t.Helper()
+	s := tstruct{}
+	var last int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("truncated struct: %v", err)
+		}
+		if b == 0 {
+			return s
+		}
+		typ := b & 0x0f
+		if delta := int16(b >> 4); delta != 0 {
+			last += delta
+		} else {
+			id, _ := binary.ReadVarint(r)
+			last = int16(id)
+		}
+		s[last] = readValue(t, r, typ)
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		v, _ := binary.ReadVarint(r)
+		return v
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		b := make([]byte, n)
+		r.Read(b)
+		return string(b)
+	case thriftList:
+		h, _ := r.ReadByte()
+		n := uint64(h >> 4)
+		if n == 15 {
+			n, _ = binary.ReadUvarint(r)
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = readValue(t, r, h&0x0f)
+		}
+		return list
+	case thriftStruct:
+		return readStruct(t, r)
+	}
+	t.Fatalf("unsupported thrift type %d", typ)
+	return nil
+}
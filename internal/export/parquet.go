@@ -0,0 +1,270 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"plarix-action/internal/money"
+)
+
+// Parquet format constants (parquet.thrift).
+const (
+	parquetMagic = "PAR1"
+
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedDecimal         = 5
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageTypeData = 0
+	codecNone    = 0
+
+	// Costs are picodollars, i.e. DECIMAL(18,12) in an INT64.
+	decimalPrecision = 18
+	decimalScale     = 12
+)
+
+// WriteParquet writes t as a Parquet file: a single row group with one
+// uncompressed, PLAIN-encoded data page per column. Every column is
+// OPTIONAL so that empty cells are nulls. Strings are UTF8 byte arrays,
+// costs DECIMAL(18,12) and timestamps UTC TIMESTAMP_MILLIS.
+func WriteParquet(w io.Writer, t *Table) error {
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, parquetMagic); err != nil {
+		return err
+	}
+
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	chunks := make([]chunk, len(t.Columns))
+	for i, c := range t.Columns {
+		header, data := encodeColumnPage(c, t.Rows)
+		chunks[i].offset = cw.n
+		if _, err := cw.Write(header); err != nil {
+			return err
+		}
+		if _, err := cw.Write(data); err != nil {
+			return err
+		}
+		chunks[i].size = cw.n - chunks[i].offset
+	}
+
+	var totalSize int64
+	for _, ch := range chunks {
+		totalSize += ch.size
+	}
+
+	// FileMetaData
+	var m thriftWriter
+	m.begin()
+	m.i32(1, 1) // version
+	m.list(2, thriftStruct, len(t.Columns)+1)
+	m.begin() // root schema element
+	m.str(4, "schema")
+	m.i32(5, int32(len(t.Columns)))
+	m.end()
+	for _, c := range t.Columns {
+		m.begin()
+		writeSchemaElement(&m, c)
+		m.end()
+	}
+	m.i64(3, int64(t.Rows))
+	m.list(4, thriftStruct, 1)
+	m.begin() // RowGroup
+	m.list(1, thriftStruct, len(t.Columns))
+	for i, c := range t.Columns {
+		m.begin() // ColumnChunk
+		m.i64(2, chunks[i].offset)
+		m.structField(3) // ColumnMetaData
+		m.i32(1, physicalType(c.Kind))
+		m.list(2, thriftI32, 2)
+		m.elemI32(encodingPlain)
+		m.elemI32(encodingRLE)
+		m.list(3, thriftBinary, 1)
+		m.elemStr(c.Name)
+		m.i32(4, codecNone)
+		m.i64(5, int64(t.Rows))
+		m.i64(6, chunks[i].size)
+		m.i64(7, chunks[i].size)
+		m.i64(9, chunks[i].offset)
+		m.end()
+		m.end()
+	}
+	m.i64(2, totalSize)
+	m.i64(3, int64(t.Rows))
+	m.end()
+	m.str(6, "plarix-scan")
+	m.end()
+
+	footer := m.buf.Bytes()
+	if _, err := cw.Write(footer); err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	if _, err := cw.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(cw, parquetMagic)
+	return err
+}
+
+// writeSchemaElement writes the fields of a leaf SchemaElement.
+func writeSchemaElement(m *thriftWriter, c Column) {
+	m.i32(1, physicalType(c.Kind))
+	m.i32(3, repetitionOptional)
+	m.str(4, c.Name)
+	switch c.Kind {
+	case KindString:
+		m.i32(6, convertedUTF8)
+		m.structField(10) // LogicalType
+		m.structField(1)  // STRING
+		m.end()
+		m.end()
+	case KindDecimal:
+		m.i32(6, convertedDecimal)
+		m.i32(7, decimalScale)
+		m.i32(8, decimalPrecision)
+		m.structField(10) // LogicalType
+		m.structField(5)  // DECIMAL
+		m.i32(1, decimalScale)
+		m.i32(2, decimalPrecision)
+		m.end()
+		m.end()
+	case KindTimestamp:
+		m.i32(6, convertedTimestampMillis)
+		m.structField(10) // LogicalType
+		m.structField(8)  // TIMESTAMP
+		m.boolean(1, true)
+		m.structField(2) // TimeUnit
+		m.structField(1) // MILLIS
+		m.end()
+		m.end()
+		m.end()
+		m.end()
+	}
+}
+
+func physicalType(k Kind) int32 {
+	switch k {
+	case KindInt, KindDecimal, KindTimestamp:
+		return typeInt64
+	case KindFloat:
+		return typeDouble
+	case KindBool:
+		return typeBoolean
+	}
+	return typeByteArray
+}
+
+// encodeColumnPage returns the page header and body of the single data page
+// holding column c.
+func encodeColumnPage(c Column, rows int) (header, data []byte) {
+	levels := make([]byte, rows)
+	var values bytes.Buffer
+	var bits []bool
+	var scratch [8]byte
+
+	for row := 0; row < rows; row++ {
+		v, ok := c.Value(row)
+		if !ok {
+			continue
+		}
+		levels[row] = 1
+		switch x := v.(type) {
+		case string:
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(x)))
+			values.Write(scratch[:4])
+			values.WriteString(x)
+		case int64:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(x))
+			values.Write(scratch[:])
+		case money.USD:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(x))
+			values.Write(scratch[:])
+		case time.Time:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(x.UnixMilli()))
+			values.Write(scratch[:])
+		case float64:
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(x))
+			values.Write(scratch[:])
+		case bool:
+			bits = append(bits, x)
+		}
+	}
+	if c.Kind == KindBool {
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, b := range bits {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		values.Write(packed)
+	}
+
+	defs := encodeLevels(levels)
+	var body bytes.Buffer
+	binary.LittleEndian.PutUint32(scratch[:4], uint32(len(defs)))
+	body.Write(scratch[:4])
+	body.Write(defs)
+	body.Write(values.Bytes())
+	data = body.Bytes()
+
+	var h thriftWriter
+	h.begin()
+	h.i32(1, pageTypeData)
+	h.i32(2, int32(len(data)))
+	h.i32(3, int32(len(data)))
+	h.structField(5) // DataPageHeader
+	h.i32(1, int32(rows))
+	h.i32(2, encodingPlain)
+	h.i32(3, encodingRLE)
+	h.i32(4, encodingRLE)
+	h.end()
+	h.end()
+	return h.buf.Bytes(), data
+}
+
+// encodeLevels encodes definition levels (0 or 1) with the RLE/bit-packing
+// hybrid at bit width 1, using RLE runs only.
+func encodeLevels(levels []byte) []byte {
+	var out bytes.Buffer
+	var b [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		n := binary.PutUvarint(b[:], uint64(j-i)<<1)
+		out.Write(b[:n])
+		out.WriteByte(levels[i])
+		i = j
+	}
+	return out.Bytes()
+}
+
+// countingWriter tracks the file offset for column chunk metadata.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"plarix-action/internal/money"
+)
+
+// Bucket totals the calls whose timestamps fall in [Start, Start+width) of
+// a summary's time series.
+type Bucket struct {
+	Start            time.Time             `json:"start"`
+	Calls            int                   `json:"calls"`
+	UnknownCostCalls int                   `json:"unknown_cost_calls,omitempty"`
+	InputTokens      int                   `json:"input_tokens"`
+	OutputTokens     int                   `json:"output_tokens"`
+	KnownCostUSD     money.USD             `json:"known_cost_usd"`
+	Providers        map[string]ModelStats `json:"providers,omitempty"`
+	Models           map[string]ModelStats `json:"models,omitempty"`
+}
+
+// ParseBucketWidth parses a time-series bucket width: "minute", "hour",
+// "day", or a Go duration of at least a second such as "15m". An empty
+// string disables the time series.
+func ParseBucketWidth(s string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return 0, nil
+	case "minute":
+		return time.Minute, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bucket width %q (want minute, hour, day or a duration)", s)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("bucket width %s is shorter than a second", d)
+	}
+	return d, nil
+}
+
+// SetBucketWidth makes the aggregator keep a time series of the calls in
+// buckets of the given width, aligned to UTC (days start at midnight UTC).
+// Call it before adding entries; zero disables the series. Memory grows with
+// the number of non-empty buckets.
+func (a *Aggregator) SetBucketWidth(width time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.width = width
+	a.buckets = make(map[int64]*Bucket)
+}
+
+// addBucket adds e to its time bucket. Entries without a valid timestamp
+// are left out of the series.
+func (a *Aggregator) addBucket(e Entry) {
+	t, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		return
+	}
+	start := t.UTC().Truncate(a.width)
+	b := a.buckets[start.Unix()]
+	if b == nil {
+		b = &Bucket{
+			Start:     start,
+			Providers: make(map[string]ModelStats),
+			Models:    make(map[string]ModelStats),
+		}
+		a.buckets[start.Unix()] = b
+	}
+	b.Calls++
+	b.InputTokens += e.InputTokens
+	b.OutputTokens += e.OutputTokens
+	if e.CostKnown {
+		b.KnownCostUSD += e.CostUSD
+	} else {
+		b.UnknownCostCalls++
+	}
+	if e.Provider != "" {
+		b.Providers[e.Provider] = addStats(b.Providers[e.Provider], e)
+	}
+	b.Models[e.Model] = addStats(b.Models[e.Model], e)
+}
+
+// series returns copies of the non-empty buckets, oldest first.
+func (a *Aggregator) series() []Bucket {
+	if len(a.buckets) == 0 {
+		return nil
+	}
+	out := make([]Bucket, 0, len(a.buckets))
+	for _, b := range a.buckets {
+		c := *b
+		c.Providers = copyStats(b.Providers)
+		c.Models = copyStats(b.Models)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
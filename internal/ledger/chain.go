@@ -0,0 +1,154 @@
+package ledger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// HMACKeyEnv names the environment variable holding the key for
+// HMAC-chained ledgers, used by both the writer and `ledger verify`.
+const HMACKeyEnv = "PLARIX_LEDGER_HMAC_KEY"
+
+// Hash algorithms recorded as the prefix of Entry.PrevHash.
+const (
+	ChainSHA256     = "sha256"
+	ChainHMACSHA256 = "hmac-sha256"
+)
+
+// chainHash returns the PrevHash value for the entry following line: the
+// SHA-256 of the raw JSON line, or its HMAC-SHA256 when key is set. The first
+// entry of a ledger chains to the hash of an empty line.
+func chainHash(key, line []byte) string {
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(line)
+		return ChainHMACSHA256 + ":" + hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256(line)
+	return ChainSHA256 + ":" + hex.EncodeToString(sum[:])
+}
+
+// lastLine returns the raw JSON of the last entry in the ledger at path,
+// looking at rotated segments when the active file has none. It returns nil
+// for an empty ledger.
+func lastLine(path string) ([]byte, error) {
+	files, err := ledgerFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		var last []byte
+		_, err := scanLines(files[i], func(_ int, raw []byte, _ Entry) error {
+			last = append(last[:0], raw...)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+// ChainBreak locates the first entry whose hash chain does not verify.
+type ChainBreak struct {
+	Path   string
+	Line   int
+	Reason string
+}
+
+func (b ChainBreak) String() string {
+	return fmt.Sprintf("%s:%d: %s", b.Path, b.Line, b.Reason)
+}
+
+// VerifyResult summarizes the verification of a hash-chained ledger.
+//
+// Entries written before chaining was enabled are counted but cannot be
+// verified. Pruned is set when the ledger starts in a rotated segment whose
+// first entry links to an entry that is no longer present, i.e. older
+// segments were deleted by retention. A chain starting in the active file
+// must start from the beginning. Removing entries from the very end of a
+// ledger cannot be detected by a chain.
+type VerifyResult struct {
+	Entries   int
+	Chained   int
+	Algorithm string
+	Pruned    bool
+	Torn      []TornLine
+	Break     *ChainBreak
+}
+
+// OK reports whether the ledger is chained and every link verified.
+func (r VerifyResult) OK() bool {
+	return r.Break == nil && r.Chained > 0
+}
+
+// errChainBroken stops scanning at the first broken link.
+var errChainBroken = errors.New("chain broken")
+
+// Verify checks the hash chain of the ledger at path and its rotated
+// segments. key is the HMAC key; when set, every chained entry must be
+// HMAC-signed. An HMAC-chained ledger cannot be verified without its key.
+func Verify(path string, key []byte) (VerifyResult, error) {
+	var r VerifyResult
+	var prev []byte
+	started := false
+
+	fail := func(file string, lineNo int, format string, args ...interface{}) error {
+		r.Break = &ChainBreak{Path: file, Line: lineNo, Reason: fmt.Sprintf(format, args...)}
+		return errChainBroken
+	}
+
+	torn, err := scanAllLines(path, func(file string, lineNo int, raw []byte, e Entry) error {
+		r.Entries++
+		defer func() { prev = append(prev[:0], raw...) }()
+
+		if e.PrevHash == "" {
+			if started {
+				return fail(file, lineNo, "entry has no prev_hash")
+			}
+			return nil
+		}
+
+		algo, _, _ := strings.Cut(e.PrevHash, ":")
+		switch {
+		case algo != ChainSHA256 && algo != ChainHMACSHA256:
+			return fail(file, lineNo, "unknown prev_hash algorithm %q", algo)
+		case algo == ChainHMACSHA256 && len(key) == 0:
+			return fmt.Errorf("ledger is HMAC-chained; set %s to verify it", HMACKeyEnv)
+		case algo == ChainSHA256 && len(key) > 0:
+			return fail(file, lineNo, "entry is not HMAC-signed")
+		case started && algo != r.Algorithm:
+			return fail(file, lineNo, "hash algorithm changed from %s to %s", r.Algorithm, algo)
+		}
+
+		want := chainHash(key, prev)
+		if e.PrevHash != want {
+			if started || r.Entries > 1 {
+				return fail(file, lineNo, "prev_hash does not match the preceding entry (entries edited, reordered or removed)")
+			}
+			// The first entry on disk links to something that is gone. Only
+			// retention deletes whole segments before a rotated one.
+			if file == path {
+				return fail(file, lineNo, "prev_hash does not start the chain (entries removed from the start, or wrong key)")
+			}
+			r.Pruned = true
+		}
+		started = true
+		r.Algorithm = algo
+		r.Chained++
+		return nil
+	})
+	r.Torn = torn
+	if err != nil && err != errChainBroken {
+		return r, err
+	}
+	return r, nil
+}
@@ -0,0 +1,170 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeChained writes one entry per model to a hash-chained ledger.
+func writeChained(t *testing.T, path string, opts Options, models ...string) {
+	t.Helper()
+	opts.Chain = true
+	w, err := OpenWriter(path, opts)
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, models...)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestHashChainAcrossReopenAndRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	opts := Options{MaxBytes: 400, Compress: true, now: now}
+
+	writeChained(t, path, opts, "a", "b", "c")
+	writeChained(t, path, opts, "d", "e", "f", "g")
+
+	if segments, _ := Segments(path); len(segments) == 0 {
+		t.Fatal("expected rotated segments")
+	}
+	r, err := Verify(path, nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !r.OK() || r.Chained != 7 || r.Algorithm != ChainSHA256 || r.Pruned {
+		t.Errorf("Verify = %+v, want OK with 7 sha256-chained entries", r)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(lines []string) []string
+		wantLine int
+		wantText string
+	}{
+		{"edited entry", func(l []string) []string {
+			l[1] = strings.Replace(l[1], `"model":"b"`, `"model":"x"`, 1)
+			return l
+		}, 3, "does not match"},
+		{"deleted entry", func(l []string) []string {
+			return append(l[:1], l[2:]...)
+		}, 2, "does not match"},
+		{"reordered entries", func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, 2, "does not match"},
+		{"truncated head", func(l []string) []string {
+			return l[2:]
+		}, 1, "does not start the chain"},
+		{"stripped prev_hash", func(l []string) []string {
+			i := strings.Index(l[3], `,"prev_hash"`)
+			l[3] = l[3][:i] + "}"
+			return l
+		}, 4, "no prev_hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+			writeChained(t, path, Options{}, "a", "b", "c", "d")
+
+			data, _ := os.ReadFile(path)
+			lines := tt.tamper(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := Verify(path, nil)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if r.OK() || r.Break == nil {
+				t.Fatalf("Verify = %+v, want a break", r)
+			}
+			if r.Break.Line != tt.wantLine || !strings.Contains(r.Break.Reason, tt.wantText) {
+				t.Errorf("Break = %s, want line %d containing %q", r.Break, tt.wantLine, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	key := []byte("chargeback-secret")
+	writeChained(t, path, Options{HMACKey: key}, "a", "b", "c")
+
+	if r, err := Verify(path, key); err != nil || !r.OK() || r.Algorithm != ChainHMACSHA256 {
+		t.Errorf("Verify(key) = %+v, %v; want OK hmac-sha256", r, err)
+	}
+	if _, err := Verify(path, nil); err == nil || !strings.Contains(err.Error(), HMACKeyEnv) {
+		t.Errorf("Verify(no key) error = %v, want hint about %s", err, HMACKeyEnv)
+	}
+	if r, _ := Verify(path, []byte("wrong")); r.OK() {
+		t.Error("Verify(wrong key) = OK, want a break")
+	}
+
+	// With a single entry there is no later link to catch the wrong key.
+	single := filepath.Join(t.TempDir(), "single.jsonl")
+	writeChained(t, single, Options{HMACKey: key}, "a")
+	if r, _ := Verify(single, []byte("wrong")); r.OK() || r.Break == nil || r.Break.Line != 1 {
+		t.Errorf("Verify(one entry, wrong key) = %+v, want a break at line 1", r)
+	}
+
+	// A plain SHA-256 chain does not satisfy a verifier holding a key.
+	plain := filepath.Join(t.TempDir(), "plain.jsonl")
+	writeChained(t, plain, Options{}, "a")
+	if r, _ := Verify(plain, key); r.OK() || r.Break == nil || !strings.Contains(r.Break.Reason, "HMAC") {
+		t.Errorf("Verify(sha256 ledger, key) = %+v, want not-HMAC-signed break", r)
+	}
+}
+
+func TestVerifyUnchainedAndPruned(t *testing.T) {
+	dir := t.TempDir()
+
+	unchained := filepath.Join(dir, "unchained.jsonl")
+	w, _ := NewWriter(unchained)
+	writeModels(t, w, "a", "b")
+	w.Close()
+	if r, err := Verify(unchained, nil); err != nil || r.OK() || r.Chained != 0 || r.Entries != 2 {
+		t.Errorf("Verify(unchained) = %+v, %v; want not OK with 0 chained", r, err)
+	}
+
+	// Chaining enabled later: the prefix is unverifiable but the chain links to it.
+	writeChained(t, unchained, Options{}, "c", "d")
+	if r, err := Verify(unchained, nil); err != nil || !r.OK() || r.Chained != 2 || r.Entries != 4 {
+		t.Errorf("Verify(chained suffix) = %+v, %v; want OK with 2 of 4 chained", r, err)
+	}
+
+	// Retention drops the oldest segment, so the chain starts mid-way.
+	pruned := filepath.Join(dir, "pruned.jsonl")
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	writeChained(t, pruned, Options{MaxBytes: 400, MaxSegments: 1, now: now}, "a", "b", "c", "d", "e", "f")
+	r, err := Verify(pruned, nil)
+	if err != nil || !r.OK() || !r.Pruned {
+		t.Errorf("Verify(pruned) = %+v, %v; want OK and Pruned", r, err)
+	}
+
+	// MaxAge would expire every segment (the clock is years past their
+	// modification times), but a chained ledger keeps the newest one so that
+	// the pruned start is still recognized.
+	aged := filepath.Join(dir, "aged.jsonl")
+	clock, advance := fakeClock(time.Now().AddDate(5, 0, 0))
+	opts := Options{Interval: RotateHourly, MaxAge: time.Hour, now: clock}
+	for _, m := range []string{"a", "b", "c", "d"} {
+		writeChained(t, aged, opts, m)
+		advance(3 * time.Hour)
+	}
+	if segments, _ := Segments(aged); len(segments) != 1 {
+		t.Fatalf("segments = %v, want the newest one kept", segments)
+	}
+	r, err = Verify(aged, nil)
+	if err != nil || !r.OK() || !r.Pruned {
+		t.Errorf("Verify(aged) = %+v, %v; want OK and Pruned", r, err)
+	}
+}
@@ -0,0 +1,432 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dbIndexVersion is bumped whenever the saved index layout changes; an index
+// with another version is rebuilt from the data file.
+const dbIndexVersion = 2
+
+// DB is an embedded ledger store with indexed queries.
+//
+// Entries are appended to a data file in the ledger's JSONL format, so every
+// ledger tool (report, export, verify) can read it directly. Alongside it the
+// DB keeps an index by time, provider, model and tag, which answers a Query
+// by reading only the matching lines. The index is saved to path + ".idx" on
+// Close; on open it is loaded and brought up to date with any entries
+// appended since, or rebuilt from the data file if it is missing or stale.
+// The index file can be deleted at any time.
+//
+// A DB does not rotate its data file.
+type DB struct {
+	path string
+	w    *Writer // nil when read-only
+	r    *os.File
+
+	mu  sync.RWMutex
+	idx dbIndex
+}
+
+// dbRow locates one entry in the data file.
+type dbRow struct {
+	Off  int64
+	Len  int32
+	TS   int64    // Unix nanoseconds, or math.MinInt64 if unparsable
+	Keys []uint32 // index keys (see providerKey), as IDs into Strings
+}
+
+// dbIndex is the saved part of the index plus the lookup tables derived
+// from it on load.
+type dbIndex struct {
+	Version     int
+	Size        int64  // bytes of the data file covered by Rows
+	Fingerprint []byte // hash of the first and last covered rows
+	Strings     []string
+	Rows        []dbRow
+
+	ids      map[string]uint32   // Strings -> ID
+	postings map[uint32][]uint32 // key ID -> row numbers, ascending
+	byTime   []uint32            // row numbers ordered by TS, then row
+}
+
+// OpenDB opens or creates the store at path for writing. opts control
+// durability and hash chaining as for OpenWriter; rotation and retention
+// options are rejected.
+func OpenDB(path string, opts Options) (*DB, error) {
+	if opts.MaxBytes > 0 || opts.Interval > 0 || opts.MaxAge > 0 || opts.MaxSegments > 0 {
+		return nil, errors.New("store does not support rotation or retention")
+	}
+	w, err := OpenWriter(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(path, w)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenDBReadOnly opens an existing store for queries. A partially written
+// last entry, e.g. from a writer in another process, is not indexed.
+func OpenDBReadOnly(path string) (*DB, error) {
+	return openDB(path, nil)
+}
+
+func openDB(path string, w *Writer) (*DB, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{path: path, w: w, r: r}
+	if err := db.load(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// load reads the saved index if it matches the data file and indexes the
+// entries after it.
+func (db *DB) load() error {
+	info, err := db.r.Stat()
+	if err != nil {
+		return err
+	}
+	db.idx = dbIndex{}
+	if f, err := os.Open(db.path + ".idx"); err == nil {
+		var saved dbIndex
+		if gob.NewDecoder(bufio.NewReader(f)).Decode(&saved) == nil && db.validIndex(&saved, info.Size()) {
+			db.idx = saved
+		}
+		f.Close()
+	}
+	db.idx.Version = dbIndexVersion
+	db.idx.rebuild()
+
+	saved := len(db.idx.Rows)
+	if err := db.catchUp(); err != nil {
+		return err
+	}
+	if db.w != nil && len(db.idx.Rows) > saved {
+		// Save now so that readers need not repeat the work.
+		return db.save()
+	}
+	return nil
+}
+
+// validIndex reports whether a saved index describes a prefix of the data
+// file: its last row must end exactly at a line boundary it covers, and its
+// first and last rows must still hold the lines they were saved with, which
+// catches a data file rewritten in place (e.g. by ledger migrate).
+func (db *DB) validIndex(x *dbIndex, size int64) bool {
+	if x.Version != dbIndexVersion || x.Size > size {
+		return false
+	}
+	if len(x.Rows) == 0 {
+		return x.Size == 0
+	}
+	last := x.Rows[len(x.Rows)-1]
+	if last.Off+int64(last.Len) != x.Size {
+		return false
+	}
+	var b [1]byte
+	if _, err := db.r.ReadAt(b[:], x.Size-1); err != nil || b[0] != '\n' {
+		return false
+	}
+	sum, err := db.fingerprint(x)
+	return err == nil && bytes.Equal(sum, x.Fingerprint)
+}
+
+// fingerprint hashes the lines of the first and last rows of x as they are
+// in the data file.
+func (db *DB) fingerprint(x *dbIndex) ([]byte, error) {
+	h := sha256.New()
+	if n := len(x.Rows); n > 0 {
+		for _, row := range []dbRow{x.Rows[0], x.Rows[n-1]} {
+			if _, err := io.Copy(h, io.NewSectionReader(db.r, row.Off, int64(row.Len))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// catchUp indexes the complete lines after the covered part of the data
+// file. Truncated lines are skipped as in ScanFile.
+func (db *DB) catchUp() error {
+	off := db.idx.Size
+	br := bufio.NewReaderSize(io.NewSectionReader(db.r, off, math.MaxInt64-off), 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil // an unterminated tail is left for a later open
+		}
+		if err != nil {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var e Entry
+			if err := json.Unmarshal(trimmed, &e); err == nil {
+				db.idx.add(off, len(line), e)
+			} else if !isTruncatedJSON(trimmed) {
+				return fmt.Errorf("%s: offset %d: %w", db.path, off, err)
+			}
+		}
+		off += int64(len(line))
+		db.idx.Size = off
+	}
+}
+
+// save writes the index next to the data file, atomically.
+func (db *DB) save() error {
+	sum, err := db.fingerprint(&db.idx)
+	if err != nil {
+		return fmt.Errorf("save store index: %w", err)
+	}
+	db.idx.Fingerprint = sum
+
+	tmp := db.path + ".idx.tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = gob.NewEncoder(bw).Encode(&db.idx)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, db.path+".idx")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("save store index: %w", err)
+	}
+	return nil
+}
+
+// Write appends an entry to the store and indexes it.
+func (db *DB) Write(e Entry) error {
+	if db.w == nil {
+		return errors.New("store is open read-only")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	line, off, err := db.w.write(&e)
+	if line != nil {
+		db.idx.add(off, len(line), e)
+		db.idx.Size = off + int64(len(line))
+	}
+	return err
+}
+
+// Query calls fn for each entry matching q, in timestamp order.
+func (db *DB) Query(q Query, fn func(Entry) error) error {
+	db.mu.RLock()
+	rows := db.idx.match(q)
+	db.mu.RUnlock()
+
+	// The data file is append-only, so rows stay valid without the lock.
+	var buf []byte
+	for _, row := range rows {
+		if cap(buf) < int(row.Len) {
+			buf = make([]byte, row.Len)
+		}
+		buf = buf[:row.Len]
+		if _, err := db.r.ReadAt(buf, row.Off); err != nil {
+			return fmt.Errorf("%s: offset %d: %w", db.path, row.Off, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(buf, &e); err != nil {
+			return fmt.Errorf("%s: offset %d: %w", db.path, row.Off, err)
+		}
+		Upgrade(&e)
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of indexed entries.
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return len(db.idx.Rows)
+}
+
+// Close flushes the data file and, for a writable store, saves the index.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var errs []error
+	if db.w != nil {
+		errs = append(errs, db.w.Close())
+		errs = append(errs, db.save())
+	}
+	errs = append(errs, db.r.Close())
+	return errors.Join(errs...)
+}
+
+// Index keys are prefixed by the field they index.
+func providerKey(p string) string { return "p:" + p }
+func modelKey(m string) string    { return "m:" + m }
+func tagKey(k, v string) string   { return "t:" + k + "=" + v }
+
+// entryNanos is the indexed timestamp of e.
+func entryNanos(e Entry) int64 {
+	t, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		return math.MinInt64
+	}
+	return t.UnixNano()
+}
+
+// rebuild derives the lookup tables from Strings and Rows.
+func (x *dbIndex) rebuild() {
+	x.ids = make(map[string]uint32, len(x.Strings))
+	for i, s := range x.Strings {
+		x.ids[s] = uint32(i)
+	}
+	x.postings = make(map[uint32][]uint32)
+	x.byTime = make([]uint32, len(x.Rows))
+	for i, row := range x.Rows {
+		for _, k := range row.Keys {
+			x.postings[k] = append(x.postings[k], uint32(i))
+		}
+		x.byTime[i] = uint32(i)
+	}
+	sort.SliceStable(x.byTime, func(i, j int) bool {
+		return x.Rows[x.byTime[i]].TS < x.Rows[x.byTime[j]].TS
+	})
+}
+
+func (x *dbIndex) intern(s string) uint32 {
+	id, ok := x.ids[s]
+	if !ok {
+		id = uint32(len(x.Strings))
+		x.Strings = append(x.Strings, s)
+		x.ids[s] = id
+	}
+	return id
+}
+
+// add indexes the entry stored at off.
+func (x *dbIndex) add(off int64, n int, e Entry) {
+	row := dbRow{Off: off, Len: int32(n), TS: entryNanos(e)}
+	keys := []string{providerKey(e.Provider), modelKey(e.Model)}
+	for k, v := range e.Tags {
+		keys = append(keys, tagKey(k, v))
+	}
+	id := uint32(len(x.Rows))
+	for _, k := range keys {
+		kid := x.intern(k)
+		row.Keys = append(row.Keys, kid)
+		x.postings[kid] = append(x.postings[kid], id)
+	}
+	x.Rows = append(x.Rows, row)
+
+	// Entries normally arrive in time order; insert otherwise.
+	i := sort.Search(len(x.byTime), func(i int) bool { return x.Rows[x.byTime[i]].TS > row.TS })
+	x.byTime = append(x.byTime, 0)
+	copy(x.byTime[i+1:], x.byTime[i:])
+	x.byTime[i] = id
+}
+
+// match returns the rows selected by q, in timestamp order.
+func (x *dbIndex) match(q Query) []dbRow {
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		lo++ // exclude unparsable timestamps
+	}
+	if !q.Since.IsZero() {
+		lo = q.Since.UnixNano()
+	}
+	if !q.Until.IsZero() {
+		hi = q.Until.UnixNano()
+	}
+
+	var keys []string
+	if q.Provider != "" {
+		keys = append(keys, providerKey(q.Provider))
+	}
+	if q.Model != "" {
+		keys = append(keys, modelKey(q.Model))
+	}
+	for k, v := range q.Tags {
+		keys = append(keys, tagKey(k, v))
+	}
+
+	var ids []uint32
+	if len(keys) == 0 {
+		i := sort.Search(len(x.byTime), func(i int) bool { return x.Rows[x.byTime[i]].TS >= lo })
+		j := sort.Search(len(x.byTime), func(i int) bool { return x.Rows[x.byTime[i]].TS >= hi })
+		ids = x.byTime[i:j]
+	} else {
+		lists := make([][]uint32, len(keys))
+		for i, k := range keys {
+			id, ok := x.ids[k]
+			if !ok {
+				return nil
+			}
+			lists[i] = x.postings[id]
+		}
+		sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+		ids = lists[0]
+		for _, l := range lists[1:] {
+			ids = intersect(ids, l)
+		}
+		var inRange []uint32
+		for _, id := range ids {
+			if ts := x.Rows[id].TS; ts >= lo && ts < hi {
+				inRange = append(inRange, id)
+			}
+		}
+		ids = inRange
+		sort.SliceStable(ids, func(i, j int) bool { return x.Rows[ids[i]].TS < x.Rows[ids[j]].TS })
+	}
+
+	rows := make([]dbRow, len(ids))
+	for i, id := range ids {
+		rows[i] = x.Rows[id]
+	}
+	return rows
+}
+
+// intersect returns the row numbers in both ascending lists.
+func intersect(a, b []uint32) []uint32 {
+	var out []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
@@ -1,23 +1,44 @@
 // Package ledger handles recording and aggregating LLM API call data.
 //
 // Purpose: Write per-call records to JSONL and aggregate totals.
-// Public API: Entry, Writer, Summary, Aggregator
-// Usage: Create a Writer to record entries, then aggregate for summary.
+// Public API: Entry, Writer, Options, Store, Query, DB, ReadFile, ReadAll,
+// ScanFile, ScanAll, Verify, Migrate, Schema, Summary, Aggregator
+// Usage: Create a Writer (OpenWriter for rotation) or a DB (OpenDB for
+// indexed queries) to record entries, then aggregate for summary.
 package ledger
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"plarix-action/internal/money"
 )
 
 // Entry represents a single LLM API call record.
 //
+// SchemaVersion identifies the line format (see SchemaVersion and Schema);
+// the Writer fills it in, and readers upgrade older entries.
+//
+// Costs are exact fixed-point amounts (money.USD), written to JSONL as exact
+// decimal numbers; rounding happens only when reports are rendered.
+//
 // Design note: RawUsage is preserved to allow debugging of new provider formats
 // without losing data. CostKnown is critical: it distinguishes "free tier" ($0.00)
-// from "unknown model" (which implies missing pricing data).
+// from "unknown model" (which implies missing pricing data). PricingTier records
+// which token-count tier of the model's price was applied, if any. ServiceTier
+// and Batch capture how the provider billed the call (e.g. OpenAI "flex", or a
+// result fetched from a batch API), which select discount/premium multipliers.
 type Entry struct {
+	SchemaVersion int                    `json:"schema_version"`
 	Timestamp     string                 `json:"ts"`
 	Provider      string                 `json:"provider"`
 	Endpoint      string                 `json:"endpoint"`
@@ -25,83 +46,474 @@ type Entry struct {
 	InputTokens   int                    `json:"input_tokens,omitempty"`
 	OutputTokens  int                    `json:"output_tokens,omitempty"`
 	RawUsage      map[string]interface{} `json:"raw_usage,omitempty"`
-	CostUSD       float64                `json:"cost_usd,omitempty"`
+	CostUSD       money.USD              `json:"cost_usd,omitempty"`
 	CostKnown     bool                   `json:"cost_known"`
 	UnknownReason string                 `json:"unknown_reason,omitempty"`
+	CostSource    string                 `json:"cost_source,omitempty"`
+	PricingTier   string                 `json:"pricing_tier,omitempty"`
+	ServiceTier   string                 `json:"service_tier,omitempty"`
+	Batch         bool                   `json:"batch,omitempty"`
 	RequestID     string                 `json:"request_id,omitempty"`
 	Streaming     bool                   `json:"streaming"`
+
+	// Provider-reported cost (e.g. OpenRouter usage.cost). When present it is
+	// authoritative; CostMismatch flags a disagreement with the pricing table,
+	// whose figure is then kept in ComputedCostUSD.
+	ReportedCostUSD  *money.USD `json:"reported_cost_usd,omitempty"`
+	UpstreamProvider string     `json:"upstream_provider,omitempty"`
+	CostMismatch     bool       `json:"cost_mismatch,omitempty"`
+	ComputedCostUSD  money.USD  `json:"computed_cost_usd,omitempty"`
+
+	// Tags are caller-supplied labels, from the X-Plarix-Tags request
+	// header or the --tag flag. DurationMS is the time from the request to
+	// the end of the response and TTFBMS the time to the response headers,
+	// both measured at the proxy.
+	Tags       map[string]string `json:"tags,omitempty"`
+	DurationMS int64             `json:"duration_ms,omitempty"`
+	TTFBMS     int64             `json:"ttfb_ms,omitempty"`
+
+	// PrevHash links the entry to the previous ledger line when hash
+	// chaining is enabled ("sha256:<hex>" or "hmac-sha256:<hex>"; see Verify).
+	PrevHash string `json:"prev_hash,omitempty"`
 }
 
+// ParseTags parses comma-separated key=value pairs, e.g.
+// "team=search,env=prod". Keys and values are trimmed; malformed pairs are
+// skipped and reported in the error alongside the pairs that did parse.
+func ParseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	var bad []string
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			bad = append(bad, strings.TrimSpace(pair))
+			continue
+		}
+		tags[k] = v
+	}
+	if len(bad) > 0 {
+		return tags, fmt.Errorf("invalid tag %q (want key=value)", strings.Join(bad, ","))
+	}
+	return tags, nil
+}
+
+// Cost sources recorded in Entry.CostSource.
+const (
+	CostSourcePricingTable = "pricing_table"
+	CostSourceProvider     = "provider"
+)
+
 // Summary holds aggregated statistics from all entries.
 type Summary struct {
 	TotalCalls        int                   `json:"total_calls"`
 	KnownCostCalls    int                   `json:"known_cost_calls"`
 	UnknownCostCalls  int                   `json:"unknown_cost_calls"`
-	TotalKnownCostUSD float64               `json:"total_known_cost_usd"`
+	CostMismatchCalls int                   `json:"cost_mismatch_calls,omitempty"`
+	TotalKnownCostUSD money.USD             `json:"total_known_cost_usd"`
 	TotalInputTokens  int                   `json:"total_input_tokens"`
 	TotalOutputTokens int                   `json:"total_output_tokens"`
 	ModelBreakdown    map[string]ModelStats `json:"model_breakdown"`
+	ProviderBreakdown map[string]ModelStats `json:"provider_breakdown,omitempty"`
+	TagBreakdown      map[string]ModelStats `json:"tag_breakdown,omitempty"` // keyed by "key=value"
 	UnknownReasons    map[string]int        `json:"unknown_reasons"`
 	Warnings          []string              `json:"warnings,omitempty"`
+
+	// Request timing of the calls that recorded it.
+	DurationMS *Percentiles `json:"duration_ms,omitempty"`
+	TTFBMS     *Percentiles `json:"ttfb_ms,omitempty"`
+
+	// The slowest and most expensive calls, at most TopCallsLimit each,
+	// highest first. Their raw_usage and prev_hash are omitted.
+	SlowestCalls   []Entry `json:"slowest_calls,omitempty"`
+	CostliestCalls []Entry `json:"costliest_calls,omitempty"`
+
+	// Time series of the calls, oldest first, when the aggregator has a
+	// bucket width. Buckets without calls are omitted.
+	BucketSeconds int64    `json:"bucket_seconds,omitempty"`
+	Buckets       []Bucket `json:"buckets,omitempty"`
+
+	// Optional display currency. Costs stay recorded in USD; TotalKnownCost
+	// is the USD total converted at Currency.PerUSD.
+	Currency       *Currency `json:"currency,omitempty"`
+	TotalKnownCost float64   `json:"total_known_cost,omitempty"`
+}
+
+// Currency describes the non-USD currency a summary is presented in.
+type Currency struct {
+	Code      string  `json:"code"`
+	PerUSD    float64 `json:"per_usd"`
+	RatesAsOf string  `json:"rates_as_of"`
+}
+
+// FromUSD converts a USD amount to this currency, for presentation.
+func (c *Currency) FromUSD(usd money.USD) float64 {
+	return usd.Float64() * c.PerUSD
+}
+
+// SetCurrency sets the display currency and the converted total.
+func (s *Summary) SetCurrency(c Currency) {
+	s.Currency = &c
+	s.TotalKnownCost = c.FromUSD(s.TotalKnownCostUSD)
 }
 
-// ModelStats holds per-model statistics.
+// ModelStats holds the statistics of one model, provider or tag. Timings
+// are only broken down by model.
 type ModelStats struct {
-	Calls        int     `json:"calls"`
-	InputTokens  int     `json:"input_tokens"`
-	OutputTokens int     `json:"output_tokens"`
-	KnownCostUSD float64 `json:"known_cost_usd"`
+	Calls        int          `json:"calls"`
+	InputTokens  int          `json:"input_tokens"`
+	OutputTokens int          `json:"output_tokens"`
+	KnownCostUSD money.USD    `json:"known_cost_usd"`
+	DurationMS   *Percentiles `json:"duration_ms,omitempty"`
+	TTFBMS       *Percentiles `json:"ttfb_ms,omitempty"`
 }
 
-// Writer writes entries to a JSONL file.
+// Percentiles summarize a distribution of millisecond timings. Percentiles
+// are estimates within 1% of a true value; Max is exact.
+type Percentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Writer writes entries to a JSONL file, rotating and flushing it according
+// to its Options.
 type Writer struct {
-	file *os.File
-	mu   sync.Mutex
+	path  string
+	opts  Options
+	file  *os.File
+	size  int64
+	start time.Time
+	prev  string
+	mu    sync.Mutex
+
+	// Background fsync state for SyncInterval.
+	dirty      bool
+	closed     bool
+	syncErr    error
+	stop, done chan struct{}
+	closeOnce  sync.Once
+
+	// Background compression and retention after rotation. maint
+	// serializes them; maintErr is returned by the next Write or Close.
+	maint    sync.Mutex
+	maintWG  sync.WaitGroup
+	maintErr error
 }
 
 // NewWriter creates a new ledger writer.
 // It opens the file in append-only mode, creating it if necessary.
 // This ensures that we don't lose previous run data if the process restarts.
 func NewWriter(path string) (*Writer, error) {
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	return OpenWriter(path, Options{})
+}
+
+// OpenWriter creates a ledger writer that rotates, prunes and fsyncs the
+// file at path according to opts. Like NewWriter it appends to an existing
+// file. If that file ends in a torn line (a crash mid-write), a newline is
+// appended first so new entries start on their own line; readers skip the
+// torn fragment.
+func OpenWriter(path string, opts Options) (*Writer, error) {
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
-	return &Writer{file: f}, nil
+
+	w := &Writer{path: path, opts: opts, file: f, size: info.Size(), start: opts.now().UTC()}
+	if w.size > 0 {
+		w.start = segmentStart(f, info)
+		if err := w.repairTornTail(); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("repair %s: %w", path, err)
+		}
+	}
+	if err := w.applyRetention(opts.now()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if opts.Chain {
+		last, err := lastLine(path)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("resume hash chain: %w", err)
+		}
+		w.prev = chainHash(opts.HMACKey, last)
+	}
+	if opts.Sync == SyncInterval {
+		w.startSyncLoop()
+	}
+	return w, nil
+}
+
+// repairTornTail terminates a partial last line left by a crash.
+func (w *Writer) repairTornTail() error {
+	last := make([]byte, 1)
+	if _, err := w.file.ReadAt(last, w.size-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	n, err := w.file.Write([]byte{'\n'})
+	w.size += int64(n)
+	return err
 }
 
 // Write appends an entry to the ledger file.
+//
+// If the entry is due for a new segment, the active file is rotated first.
+// When rotation fails the entry is still written to the current file and the
+// rotation error is returned.
 func (w *Writer) Write(e Entry) error {
-	if e.Timestamp == "" {
-		e.Timestamp = time.Now().UTC().Format(time.RFC3339)
-	}
+	_, _, err := w.write(&e)
+	return err
+}
 
+// write appends e, filling in its timestamp, schema version and hash link,
+// and returns the line and its offset in the active file. line is nil unless
+// the whole entry was written; an error alongside a line means a rotation or
+// fsync failed after the write.
+func (w *Writer) write(e *Entry) (line []byte, off int64, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	now := w.opts.now().UTC()
+	if e.Timestamp == "" {
+		e.Timestamp = now.Format(time.RFC3339)
+	}
+	if e.SchemaVersion == 0 {
+		e.SchemaVersion = SchemaVersion
+	}
+	if w.opts.Chain {
+		e.PrevHash = w.prev
+	}
+
 	data, err := json.Marshal(e)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	_, err = w.file.Write(append(data, '\n'))
-	return err
+	data = append(data, '\n')
+
+	var rotateErr error
+	if w.needsRotation(len(data), now) {
+		rotateErr = w.rotate(now)
+	}
+	off = w.size
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	if err != nil {
+		return nil, 0, err
+	}
+	w.dirty = true
+	if w.opts.Chain {
+		w.prev = chainHash(w.opts.HMACKey, data[:len(data)-1])
+	}
+
+	switch {
+	case w.opts.Sync == SyncAlways:
+		if err := w.syncLocked(); err != nil {
+			return data, off, fmt.Errorf("fsync ledger: %w", err)
+		}
+	case w.syncErr != nil:
+		err, w.syncErr = w.syncErr, nil
+		return data, off, fmt.Errorf("fsync ledger: %w", err)
+	}
+	if rotateErr == nil && w.maintErr != nil {
+		rotateErr, w.maintErr = w.maintErr, nil
+	}
+	return data, off, rotateErr
 }
 
-// Close closes the underlying file.
+// Close waits for background compression, flushes (unless the policy is
+// SyncNever) and closes the underlying file. Calling it again does nothing
+// and returns nil.
 func (w *Writer) Close() error {
-	return w.file.Close()
+	var err error
+	w.closeOnce.Do(func() { err = w.close() })
+	return err
+}
+
+func (w *Writer) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.maintWG.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs []error
+	if w.syncErr != nil {
+		errs = append(errs, w.syncErr)
+	}
+	if w.maintErr != nil {
+		errs = append(errs, w.maintErr)
+	}
+	if w.opts.Sync != SyncNever {
+		if err := w.syncLocked(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	w.closed = true
+	if err := w.file.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// maxLineSize bounds a single JSONL line when reading a ledger.
+// Entries are small, but RawUsage may grow with new provider fields.
+const maxLineSize = 1024 * 1024
+
+// TornLine describes a truncated line skipped while reading a ledger,
+// typically the last line written before a crash.
+type TornLine struct {
+	Path  string
+	Line  int
+	Bytes int
+}
+
+func (t TornLine) String() string {
+	return fmt.Sprintf("%s:%d: skipped truncated line (%d bytes)", t.Path, t.Line, t.Bytes)
+}
+
+// ReadFile reads all entries from a JSONL ledger file.
+// Blank lines are ignored and torn lines skipped (see ScanFile); any other
+// malformed line is reported with its line number.
+func ReadFile(path string) ([]Entry, error) {
+	var entries []Entry
+	_, err := ScanFile(path, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ScanFile calls fn for each entry of a JSONL ledger file, in order.
+// Files ending in .gz (compressed rotated segments) are decompressed, and
+// entries from older releases are upgraded to SchemaVersion.
+//
+// A torn line - one cut short by a crash, either the unterminated last line
+// or a line holding truncated JSON - is skipped and returned instead of
+// failing the read. Any other malformed line is an error.
+func ScanFile(path string, fn func(Entry) error) ([]TornLine, error) {
+	return scanLines(path, func(_ int, _ []byte, e Entry) error {
+		Upgrade(&e)
+		return fn(e)
+	})
 }
 
-// Aggregator collects entries and computes summary statistics.
+// scanLines is ScanFile, also passing each entry's line number and raw JSON
+// (valid only during the call).
+func scanLines(path string, fn func(lineNo int, raw []byte, e Entry) error) ([]TornLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var torn []TornLine
+	unterminated := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		unterminated = advance > 0 && data[advance-1] != '\n'
+		return advance, token, err
+	})
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			if unterminated || isTruncatedJSON(line) {
+				torn = append(torn, TornLine{Path: path, Line: lineNo, Bytes: len(line)})
+				continue
+			}
+			return torn, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if err := fn(lineNo, line, e); err != nil {
+			return torn, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return torn, fmt.Errorf("%s: %w", path, err)
+	}
+	return torn, nil
+}
+
+// isTruncatedJSON reports whether line is a JSON value cut off part-way.
+func isTruncatedJSON(line []byte) bool {
+	var v interface{}
+	return json.NewDecoder(bytes.NewReader(line)).Decode(&v) == io.ErrUnexpectedEOF
+}
+
+// Aggregator keeps running totals of the entries added to it. It does not
+// retain entries: memory grows with the number of distinct models,
+// providers and tags, not with the number of calls, so it can summarize a
+// long-running proxy or a ledger of any size.
 type Aggregator struct {
-	entries []Entry
 	mu      sync.Mutex
+	summary Summary
+	total   latency
+	models  map[string]*latency
+
+	width   time.Duration // time-series bucket width; 0 for none
+	buckets map[int64]*Bucket
+
+	slowest, costliest topCalls
+}
+
+// latency holds the timing sketches of one group of calls.
+type latency struct {
+	duration, ttfb quantileSketch
+}
+
+func (l *latency) add(e Entry) {
+	l.duration.add(float64(e.DurationMS))
+	l.ttfb.add(float64(e.TTFBMS))
 }
 
 // NewAggregator creates a new aggregator.
 func NewAggregator() *Aggregator {
 	return &Aggregator{
-		entries: make([]Entry, 0),
+		summary: Summary{
+			ModelBreakdown: make(map[string]ModelStats),
+			UnknownReasons: make(map[string]int),
+		},
+		models:    make(map[string]*latency),
+		slowest:   topCalls{rank: func(e *Entry) int64 { return e.DurationMS }},
+		costliest: topCalls{rank: func(e *Entry) int64 { return int64(e.CostUSD) }},
 	}
 }
 
@@ -109,55 +521,111 @@ func NewAggregator() *Aggregator {
 func (a *Aggregator) Add(e Entry) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.entries = append(a.entries, e)
-}
 
-// Summary computes aggregated statistics from all entries.
-func (a *Aggregator) Summary() Summary {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	s := &a.summary
+	s.TotalCalls++
+	s.TotalInputTokens += e.InputTokens
+	s.TotalOutputTokens += e.OutputTokens
 
-	s := Summary{
-		ModelBreakdown: make(map[string]ModelStats),
-		UnknownReasons: make(map[string]int),
+	if e.CostKnown {
+		s.KnownCostCalls++
+		s.TotalKnownCostUSD += e.CostUSD
+	} else {
+		s.UnknownCostCalls++
+		if e.UnknownReason != "" {
+			s.UnknownReasons[e.UnknownReason]++
+		}
 	}
 
-	for _, e := range a.entries {
-		s.TotalCalls++
-		s.TotalInputTokens += e.InputTokens
-		s.TotalOutputTokens += e.OutputTokens
+	if e.CostMismatch {
+		s.CostMismatchCalls++
+	}
 
-		if e.CostKnown {
-			s.KnownCostCalls++
-			s.TotalKnownCostUSD += e.CostUSD
-		} else {
-			s.UnknownCostCalls++
-			if e.UnknownReason != "" {
-				s.UnknownReasons[e.UnknownReason]++
-			}
+	// Update breakdowns
+	s.ModelBreakdown[e.Model] = addStats(s.ModelBreakdown[e.Model], e)
+	if e.Provider != "" {
+		if s.ProviderBreakdown == nil {
+			s.ProviderBreakdown = make(map[string]ModelStats)
 		}
-
-		// Update model breakdown
-		ms := s.ModelBreakdown[e.Model]
-		ms.Calls++
-		ms.InputTokens += e.InputTokens
-		ms.OutputTokens += e.OutputTokens
-		if e.CostKnown {
-			ms.KnownCostUSD += e.CostUSD
+		s.ProviderBreakdown[e.Provider] = addStats(s.ProviderBreakdown[e.Provider], e)
+	}
+	for k, v := range e.Tags {
+		if s.TagBreakdown == nil {
+			s.TagBreakdown = make(map[string]ModelStats)
 		}
-		s.ModelBreakdown[e.Model] = ms
+		tag := k + "=" + v
+		s.TagBreakdown[tag] = addStats(s.TagBreakdown[tag], e)
 	}
 
-	return s
+	a.total.add(e)
+	l := a.models[e.Model]
+	if l == nil {
+		l = &latency{}
+		a.models[e.Model] = l
+	}
+	l.add(e)
+
+	if a.width > 0 {
+		a.addBucket(e)
+	}
+	if e.DurationMS > 0 {
+		a.slowest.add(e)
+	}
+	if e.CostKnown && e.CostUSD > 0 {
+		a.costliest.add(e)
+	}
 }
 
-// Entries returns a copy of all entries.
-func (a *Aggregator) Entries() []Entry {
+func addStats(ms ModelStats, e Entry) ModelStats {
+	ms.Calls++
+	ms.InputTokens += e.InputTokens
+	ms.OutputTokens += e.OutputTokens
+	if e.CostKnown {
+		ms.KnownCostUSD += e.CostUSD
+	}
+	return ms
+}
+
+// Summary returns the statistics of the entries added so far. The result
+// is a copy and may be modified by the caller.
+func (a *Aggregator) Summary() Summary {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	result := make([]Entry, len(a.entries))
-	copy(result, a.entries)
-	return result
+
+	s := a.summary
+	s.ModelBreakdown = make(map[string]ModelStats, len(a.summary.ModelBreakdown))
+	for model, ms := range a.summary.ModelBreakdown {
+		ms.DurationMS = a.models[model].duration.percentiles()
+		ms.TTFBMS = a.models[model].ttfb.percentiles()
+		s.ModelBreakdown[model] = ms
+	}
+	s.ProviderBreakdown = copyStats(a.summary.ProviderBreakdown)
+	s.TagBreakdown = copyStats(a.summary.TagBreakdown)
+	s.UnknownReasons = make(map[string]int, len(a.summary.UnknownReasons))
+	for reason, n := range a.summary.UnknownReasons {
+		s.UnknownReasons[reason] = n
+	}
+	s.Warnings = append([]string(nil), a.summary.Warnings...)
+	s.DurationMS = a.total.duration.percentiles()
+	s.TTFBMS = a.total.ttfb.percentiles()
+	s.SlowestCalls = a.slowest.sorted()
+	s.CostliestCalls = a.costliest.sorted()
+	if a.width > 0 {
+		s.BucketSeconds = int64(a.width / time.Second)
+		s.Buckets = a.series()
+	}
+	return s
+}
+
+func copyStats(m map[string]ModelStats) map[string]ModelStats {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]ModelStats, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
 }
 
 // WriteSummary writes the summary to a JSON file.
@@ -168,3 +636,16 @@ func WriteSummary(path string, s Summary) error {
 	}
 	return os.WriteFile(path, data, 0644)
 }
+
+// ReadSummary reads a summary written by WriteSummary.
+func ReadSummary(path string) (Summary, error) {
+	var s Summary
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parse summary %s: %w", path, err)
+	}
+	return s, nil
+}
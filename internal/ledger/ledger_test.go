@@ -2,9 +2,14 @@ package ledger
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"plarix-action/internal/money"
 )
 
 func TestWriter(t *testing.T) {
@@ -22,7 +27,7 @@ func TestWriter(t *testing.T) {
 		Model:        "gpt-4o",
 		InputTokens:  100,
 		OutputTokens: 50,
-		CostUSD:      0.001,
+		CostUSD:      money.FromFloat(0.001),
 		CostKnown:    true,
 		Streaming:    false,
 	}
@@ -52,6 +57,42 @@ func TestWriter(t *testing.T) {
 	if parsed.InputTokens != 100 {
 		t.Errorf("InputTokens = %d, want 100", parsed.InputTokens)
 	}
+	if parsed.CostUSD != money.FromFloat(0.001) {
+		t.Errorf("CostUSD = %s, want 0.001", parsed.CostUSD)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	w.Write(Entry{Provider: "openai", Model: "gpt-4o", InputTokens: 10, CostKnown: true})
+	w.Write(Entry{Provider: "anthropic", Model: "claude-3-opus-20240229", OutputTokens: 5})
+	w.Close()
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[1].Model != "claude-3-opus-20240229" {
+		t.Errorf("entries[1].Model = %q, want claude-3-opus-20240229", entries[1].Model)
+	}
+	if entries[0].Timestamp == "" {
+		t.Error("Expected Timestamp to be set by Writer")
+	}
+
+	// Malformed line
+	os.WriteFile(path, []byte("{\"model\":\"gpt-4o\"}\n{bad\n"), 0644)
+	if _, err := ReadFile(path); err == nil {
+		t.Error("Expected error for malformed line")
+	}
 }
 
 func TestAggregator(t *testing.T) {
@@ -61,7 +102,7 @@ func TestAggregator(t *testing.T) {
 		Model:        "gpt-4o",
 		InputTokens:  100,
 		OutputTokens: 50,
-		CostUSD:      0.01,
+		CostUSD:      money.FromFloat(0.01),
 		CostKnown:    true,
 	})
 
@@ -77,7 +118,7 @@ func TestAggregator(t *testing.T) {
 		Model:        "claude-3-opus",
 		InputTokens:  50,
 		OutputTokens: 25,
-		CostUSD:      0.005,
+		CostUSD:      money.FromFloat(0.005),
 		CostKnown:    true,
 	})
 
@@ -99,6 +140,10 @@ func TestAggregator(t *testing.T) {
 		t.Errorf("TotalOutputTokens = %d, want 175", s.TotalOutputTokens)
 	}
 
+	if s.TotalKnownCostUSD.String() != "0.015" {
+		t.Errorf("TotalKnownCostUSD = %s, want 0.015", s.TotalKnownCostUSD)
+	}
+
 	// Check model breakdown
 	gpt4Stats := s.ModelBreakdown["gpt-4o"]
 	if gpt4Stats.Calls != 2 {
@@ -111,13 +156,199 @@ func TestAggregator(t *testing.T) {
 	}
 }
 
+func TestAggregatorExactTotals(t *testing.T) {
+	agg := NewAggregator()
+
+	// 1,000,000 calls of 0.1 micro-dollars: float64 addition drifts,
+	// fixed-point addition is exact.
+	tiny, _ := money.Parse("0.0000001")
+	for i := 0; i < 1000000; i++ {
+		agg.Add(Entry{Model: "gpt-4o-mini", CostUSD: tiny, CostKnown: true})
+	}
+
+	s := agg.Summary()
+	if s.TotalKnownCostUSD.String() != "0.1" {
+		t.Errorf("TotalKnownCostUSD = %s, want exactly 0.1", s.TotalKnownCostUSD)
+	}
+}
+
+func TestAggregatorBreakdowns(t *testing.T) {
+	agg := NewAggregator()
+	agg.Add(Entry{Provider: "openai", Model: "gpt-4o", CostUSD: money.FromFloat(1), CostKnown: true,
+		Tags: map[string]string{"team": "search", "env": "prod"}, DurationMS: 100, TTFBMS: 40})
+	agg.Add(Entry{Provider: "openai", Model: "gpt-4o-mini", CostUSD: money.FromFloat(2), CostKnown: true,
+		Tags: map[string]string{"team": "ads"}, DurationMS: 300})
+	agg.Add(Entry{Provider: "anthropic", Model: "claude-x", Tags: map[string]string{"team": "search"}})
+
+	s := agg.Summary()
+	if got := s.ProviderBreakdown["openai"]; got.Calls != 2 || got.KnownCostUSD != money.FromFloat(3) {
+		t.Errorf("ProviderBreakdown[openai] = %+v, want 2 calls, $3", got)
+	}
+	if got := s.TagBreakdown["team=search"]; got.Calls != 2 || got.KnownCostUSD != money.FromFloat(1) {
+		t.Errorf("TagBreakdown[team=search] = %+v, want 2 calls, $1", got)
+	}
+	if len(s.TagBreakdown) != 3 {
+		t.Errorf("TagBreakdown has %d tags, want 3", len(s.TagBreakdown))
+	}
+	if s.DurationMS == nil || s.DurationMS.Count != 2 || s.DurationMS.Max != 300 {
+		t.Errorf("DurationMS = %+v, want 2 timed calls, max 300", s.DurationMS)
+	}
+	if ms := s.ModelBreakdown["gpt-4o"]; ms.TTFBMS == nil || ms.TTFBMS.Count != 1 {
+		t.Errorf("ModelBreakdown[gpt-4o].TTFBMS = %+v, want 1 timed call", ms.TTFBMS)
+	}
+	if ms := s.ModelBreakdown["claude-x"]; ms.DurationMS != nil {
+		t.Errorf("untimed model has DurationMS %+v", ms.DurationMS)
+	}
+
+	// The summary is a copy.
+	s.ModelBreakdown["gpt-4o"] = ModelStats{}
+	if agg.Summary().ModelBreakdown["gpt-4o"].Calls != 1 {
+		t.Error("modifying a Summary changed the aggregator")
+	}
+}
+
+func TestAggregatorBuckets(t *testing.T) {
+	agg := NewAggregator()
+	agg.SetBucketWidth(time.Hour)
+	for _, e := range []Entry{
+		{Timestamp: "2026-10-18T09:59:59Z", Provider: "openai", Model: "gpt-4o", InputTokens: 10, CostUSD: money.FromFloat(1), CostKnown: true},
+		{Timestamp: "2026-10-18T11:00:00Z", Provider: "openai", Model: "gpt-4o", InputTokens: 20, CostUSD: money.FromFloat(2), CostKnown: true},
+		{Timestamp: "2026-10-18T09:00:00Z", Provider: "anthropic", Model: "claude-x", InputTokens: 30},
+		{Timestamp: "2026-10-18T11:30:00+01:00", Provider: "openai", Model: "gpt-4o-mini", CostUSD: money.FromFloat(4), CostKnown: true},
+		{Timestamp: "garbage", Model: "gpt-4o"},
+	} {
+		agg.Add(e)
+	}
+
+	s := agg.Summary()
+	if s.TotalCalls != 5 || s.BucketSeconds != 3600 {
+		t.Fatalf("TotalCalls = %d, BucketSeconds = %d; want 5, 3600", s.TotalCalls, s.BucketSeconds)
+	}
+	type bucket struct {
+		start       string
+		calls       int
+		unknown     int
+		inputTokens int
+		cost        string
+	}
+	want := []bucket{
+		{"2026-10-18T09:00:00Z", 2, 1, 40, "1"},
+		{"2026-10-18T10:00:00Z", 1, 0, 0, "4"},
+		{"2026-10-18T11:00:00Z", 1, 0, 20, "2"},
+	}
+	if len(s.Buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %+v", len(s.Buckets), len(want), s.Buckets)
+	}
+	for i, w := range want {
+		b := s.Buckets[i]
+		got := bucket{b.Start.Format(time.RFC3339), b.Calls, b.UnknownCostCalls, b.InputTokens, b.KnownCostUSD.String()}
+		if got != w {
+			t.Errorf("bucket %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if got := s.Buckets[0].Providers["anthropic"].Calls; got != 1 {
+		t.Errorf("bucket 0 anthropic calls = %d, want 1", got)
+	}
+	if got := s.Buckets[0].Models["gpt-4o"].KnownCostUSD; got != money.FromFloat(1) {
+		t.Errorf("bucket 0 gpt-4o cost = %s, want 1", got)
+	}
+}
+
+func TestParseBucketWidth(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"minute", time.Minute, false},
+		{"Hour", time.Hour, false},
+		{"day", 24 * time.Hour, false},
+		{"15m", 15 * time.Minute, false},
+		{"10ms", 0, true},
+		{"weekly", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBucketWidth(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBucketWidth(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAggregatorTopCalls(t *testing.T) {
+	agg := NewAggregator()
+	for i := 1; i <= 25; i++ {
+		// Durations 1..25 in scrambled order; cost rises as duration falls.
+		d := int64((i*7)%25 + 1)
+		agg.Add(Entry{
+			Model:      "gpt-4o",
+			DurationMS: d,
+			CostUSD:    money.FromFloat(float64(26-d) / 100),
+			CostKnown:  true,
+			RawUsage:   map[string]interface{}{"prompt_tokens": 1},
+		})
+	}
+	agg.Add(Entry{Model: "free", DurationMS: 0, CostKnown: true})
+
+	s := agg.Summary()
+	if len(s.SlowestCalls) != TopCallsLimit || len(s.CostliestCalls) != TopCallsLimit {
+		t.Fatalf("got %d slowest and %d costliest calls, want %d each", len(s.SlowestCalls), len(s.CostliestCalls), TopCallsLimit)
+	}
+	for i, e := range s.SlowestCalls {
+		if want := int64(25 - i); e.DurationMS != want {
+			t.Errorf("SlowestCalls[%d].DurationMS = %d, want %d", i, e.DurationMS, want)
+		}
+		if e.RawUsage != nil {
+			t.Errorf("SlowestCalls[%d] keeps raw usage", i)
+		}
+	}
+	for i, e := range s.CostliestCalls {
+		if want := int64(i + 1); e.DurationMS != want {
+			t.Errorf("CostliestCalls[%d].DurationMS = %d, want %d (cost %s)", i, e.DurationMS, want, e.CostUSD)
+		}
+	}
+}
+
+func TestQuantileSketch(t *testing.T) {
+	var s quantileSketch
+	// 1..10000 in a scrambled order.
+	for i := 0; i < 10000; i++ {
+		s.add(float64((i*7919)%10000 + 1))
+	}
+	got := s.quantiles(0, 0.5, 0.9, 0.99, 1)
+	want := []float64{1, 5000.5, 9000.1, 9900.01, 10000}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > want[i]*sketchAccuracy {
+			t.Errorf("quantile %d = %v, want %v within %v%%", i, got[i], want[i], sketchAccuracy*100)
+		}
+	}
+	if len(s.buckets) > 1000 {
+		t.Errorf("sketch uses %d buckets for 10000 values", len(s.buckets))
+	}
+}
+func TestQuantileSketchNearestRank(t *testing.T) {
+	var s quantileSketch
+	for _, v := range []float64{10, 20, 30, 40} {
+		s.add(v)
+	}
+	// Nearest rank: p90 of four values is the 4th, p50 the 2nd.
+	got := s.quantiles(0, 0.5, 0.9)
+	want := []float64{10, 20, 40}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > want[i]*sketchAccuracy {
+			t.Errorf("quantile %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
 func TestWriteSummary(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "summary.json")
 
 	s := Summary{
 		TotalCalls:        5,
-		TotalKnownCostUSD: 0.123,
+		TotalKnownCostUSD: money.FromFloat(0.123),
 	}
 
 	if err := WriteSummary(path, s); err != nil {
@@ -137,4 +368,50 @@ func TestWriteSummary(t *testing.T) {
 	if parsed.TotalCalls != 5 {
 		t.Errorf("TotalCalls = %d, want 5", parsed.TotalCalls)
 	}
+
+	read, err := ReadSummary(path)
+	if err != nil {
+		t.Fatalf("ReadSummary failed: %v", err)
+	}
+	if read.TotalKnownCostUSD != s.TotalKnownCostUSD {
+		t.Errorf("ReadSummary TotalKnownCostUSD = %s, want %s", read.TotalKnownCostUSD, s.TotalKnownCostUSD)
+	}
+}
+
+func TestSummarySetCurrency(t *testing.T) {
+	s := Summary{TotalKnownCostUSD: 2 * money.Scale}
+	s.SetCurrency(Currency{Code: "EUR", PerUSD: 0.9, RatesAsOf: "2026-10-01"})
+
+	if s.TotalKnownCost != 1.8 {
+		t.Errorf("TotalKnownCost = %f, want 1.8", s.TotalKnownCost)
+	}
+	if s.TotalKnownCostUSD != 2*money.Scale {
+		t.Errorf("TotalKnownCostUSD = %s, want unchanged 2", s.TotalKnownCostUSD)
+	}
+	if s.Currency == nil || s.Currency.Code != "EUR" {
+		t.Errorf("Currency = %+v, want EUR", s.Currency)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"team=search", map[string]string{"team": "search"}, false},
+		{" team = search , env=prod,", map[string]string{"team": "search", "env": "prod"}, false},
+		{"team=a=b", map[string]string{"team": "a=b"}, false},
+		{"team=search,oops,=x", map[string]string{"team": "search"}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTags(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTags(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
 }
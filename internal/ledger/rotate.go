@@ -0,0 +1,473 @@
+package ledger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rotation intervals accepted by Options.Interval.
+const (
+	RotateHourly = time.Hour
+	RotateDaily  = 24 * time.Hour
+)
+
+// segmentStamp is the UTC time layout embedded in rotated segment names.
+const segmentStamp = "20060102T150405Z"
+
+// Options configures rotation, retention, durability and hash chaining of a
+// ledger file.
+//
+// The zero value appends to a single file forever, which is what NewWriter
+// does. Rotated segments are renamed next to the active file using the UTC
+// start of the segment, e.g. plarix-ledger.jsonl becomes
+// plarix-ledger-20261018T000000Z.jsonl (with a .N suffix on collision and
+// .gz when compressed). ReadAll reads them back in order.
+type Options struct {
+	// MaxBytes rotates the active file before a write would take it past
+	// this size. Zero disables size-based rotation.
+	MaxBytes int64
+
+	// Interval rotates the active file when the wall clock crosses a UTC
+	// boundary of this length (RotateHourly, RotateDaily). Zero disables it.
+	Interval time.Duration
+
+	// Compress gzips segments once they are rotated out.
+	Compress bool
+
+	// MaxAge deletes rotated segments last written more than this long ago.
+	// MaxSegments keeps at most this many rotated segments (the active file
+	// is not counted). Zero disables either limit. With Chain set, the newest
+	// segment is always kept, so that Verify can tell retention from entries
+	// removed at the start of the active file.
+	MaxAge      time.Duration
+	MaxSegments int
+
+	// Sync selects the fsync policy; SyncInterval flushes every
+	// SyncInterval (DefaultSyncInterval if zero).
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+
+	// Chain records in each entry the hash of the previous line, continuing
+	// across reopen and rotation. With HMACKey set the hash is an
+	// HMAC-SHA256 under that key.
+	Chain   bool
+	HMACKey []byte
+
+	// now overrides the clock in tests.
+	now func() time.Time
+}
+
+// ParseInterval parses a rotation interval: "hourly", "daily", or a Go
+// duration such as "6h".
+func ParseInterval(s string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return 0, nil
+	case "hourly":
+		return RotateHourly, nil
+	case "daily":
+		return RotateDaily, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rotation interval %q (want hourly, daily or a duration)", s)
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("rotation interval %s is shorter than a minute", d)
+	}
+	return d, nil
+}
+
+// ParseSize parses a byte size such as "100MB", "512K" or "1048576".
+// Units are binary (1KB = 1024 bytes). An empty string means no limit.
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, mult = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 100MB)", s)
+	}
+	return n * mult, nil
+}
+
+// ParseAge parses a retention age: a Go duration, or a number of days such
+// as "30d". An empty string means no limit.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q (e.g. 30d or 720h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q (e.g. 30d or 720h)", s)
+	}
+	return d, nil
+}
+
+// segmentStart estimates when the active file at path was started: the
+// timestamp of its first entry, or its modification time as a fallback.
+func segmentStart(f *os.File, info os.FileInfo) time.Time {
+	line, _ := bufio.NewReader(io.NewSectionReader(f, 0, info.Size())).ReadBytes('\n')
+	var e struct {
+		Timestamp string `json:"ts"`
+	}
+	if json.Unmarshal(line, &e) == nil {
+		if t, err := time.Parse(time.RFC3339, e.Timestamp); err == nil {
+			return t.UTC()
+		}
+	}
+	return info.ModTime().UTC()
+}
+
+// needsRotation reports whether writing n more bytes at time now must go
+// to a new segment.
+func (w *Writer) needsRotation(n int, now time.Time) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxBytes > 0 && w.size+int64(n) > w.opts.MaxBytes {
+		return true
+	}
+	if w.opts.Interval > 0 && !now.Truncate(w.opts.Interval).Equal(w.start.Truncate(w.opts.Interval)) {
+		return true
+	}
+	return false
+}
+
+// openFile opens the new active file on rotation; tests replace it.
+var openFile = os.OpenFile
+
+// rotate renames the active file to its segment name, opens a fresh active
+// file and applies compression and retention. If the close, the rename or
+// the open fails, writing continues on the file being rotated, so the caller
+// can still write to it. Compression runs in the background, followed by
+// retention; their errors are returned by the next Write or by Close.
+func (w *Writer) rotate(now time.Time) error {
+	stamp := w.start
+	if w.opts.Interval > 0 {
+		stamp = stamp.Truncate(w.opts.Interval)
+	}
+	segment, err := w.segmentName(stamp)
+	if err != nil {
+		return err
+	}
+
+	if w.opts.Sync != SyncNever {
+		if err := w.syncLocked(); err != nil {
+			return err
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		return w.reopen(w.path, fmt.Errorf("rotate: %w", err))
+	}
+	if err := os.Rename(w.path, segment); err != nil {
+		return w.reopen(w.path, fmt.Errorf("rotate: %w", err))
+	}
+
+	f, err := openFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		// Move the segment back if possible and keep appending to it.
+		active := segment
+		if os.Rename(segment, w.path) == nil {
+			active = w.path
+		}
+		return w.reopen(active, fmt.Errorf("rotate: %w", err))
+	}
+	w.file = f
+	w.size = 0
+	w.start = now
+
+	if !w.opts.Compress {
+		if err := w.applyRetention(now); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+		return nil
+	}
+
+	w.maintWG.Add(1)
+	go func() {
+		defer w.maintWG.Done()
+		w.maint.Lock()
+		defer w.maint.Unlock()
+
+		var errs []error
+		if err := compressFile(segment); err != nil {
+			errs = append(errs, fmt.Errorf("compress %s: %w", segment, err))
+		}
+		if err := w.applyRetention(now); err != nil {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			w.mu.Lock()
+			if w.maintErr == nil {
+				w.maintErr = fmt.Errorf("rotate: %w", errors.Join(errs...))
+			}
+			w.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// reopen makes path the active file after a failed rotation and returns
+// err, extended with the reopen error if that fails too.
+func (w *Writer) reopen(path string, err error) error {
+	f, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return fmt.Errorf("%v; reopen: %w", err, openErr)
+	}
+	w.file = f
+	return err
+}
+
+// segmentName returns an unused segment path for a segment started at t.
+// Segments sharing a start time get increasing .N suffixes, so that names
+// keep sorting in write order even after retention removed some of them.
+func (w *Writer) segmentName(t time.Time) (string, error) {
+	stamp := t.UTC().Format(segmentStamp)
+	segments, err := listSegments(w.path)
+	if err != nil {
+		return "", err
+	}
+	seq := 0
+	for _, s := range segments {
+		if s.stamp == stamp && s.seq >= seq {
+			seq = s.seq + 1
+		}
+	}
+
+	dir, stem, ext := splitLedgerPath(w.path)
+	name := stem + "-" + stamp
+	if seq > 0 {
+		name += "." + strconv.Itoa(seq)
+	}
+	return filepath.Join(dir, name+ext), nil
+}
+
+// applyRetention deletes rotated segments beyond MaxAge and MaxSegments.
+func (w *Writer) applyRetention(now time.Time) error {
+	if w.opts.MaxAge <= 0 && w.opts.MaxSegments <= 0 {
+		return nil
+	}
+	segments, err := Segments(w.path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var kept []string
+	for i, s := range segments {
+		if w.opts.Chain && i == len(segments)-1 {
+			kept = append(kept, s)
+			continue
+		}
+		if w.opts.MaxAge > 0 {
+			if info, err := os.Stat(s); err == nil && now.Sub(info.ModTime()) > w.opts.MaxAge {
+				if err := os.Remove(s); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+		}
+		kept = append(kept, s)
+	}
+	if n := w.opts.MaxSegments; n > 0 && len(kept) > n {
+		for _, s := range kept[:len(kept)-n] {
+			if err := os.Remove(s); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile gzips path to path.gz and removes the original.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
+
+// Segments lists the rotated segments of the ledger at path, oldest first.
+// The active file itself is not included.
+func Segments(path string) ([]string, error) {
+	segments, err := listSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(segments))
+	for i, s := range segments {
+		paths[i] = s.path
+	}
+	return paths, nil
+}
+
+// segment is a rotated segment file and the parts of its name.
+type segment struct {
+	path  string
+	stamp string
+	seq   int
+}
+
+// listSegments finds the rotated segments of the ledger at path, sorted by
+// start time and sequence number.
+func listSegments(path string) ([]segment, error) {
+	dir, stem, ext := splitLedgerPath(path)
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(stem) +
+		`-(\d{8}T\d{6}Z)(?:\.(\d+))?` + regexp.QuoteMeta(ext) + `(?:\.gz)?$`)
+
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var found []segment
+	for _, d := range names {
+		if d.IsDir() {
+			continue
+		}
+		m := pattern.FindStringSubmatch(d.Name())
+		if m == nil {
+			continue
+		}
+		seq, _ := strconv.Atoi(m[2])
+		found = append(found, segment{path: filepath.Join(dir, d.Name()), stamp: m[1], seq: seq})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].stamp != found[j].stamp {
+			return found[i].stamp < found[j].stamp
+		}
+		return found[i].seq < found[j].seq
+	})
+	return found, nil
+}
+
+// ReadAll reads the ledger at path together with its rotated segments,
+// oldest first. Compressed segments are read transparently and torn lines
+// skipped. It fails only if neither the active file nor any segment exists.
+func ReadAll(path string) ([]Entry, error) {
+	var entries []Entry
+	_, err := ScanAll(path, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ScanAll is ScanFile over the ledger at path and its rotated segments,
+// oldest first: entries from older schema versions are upgraded. It returns
+// the torn lines skipped in all of them.
+func ScanAll(path string, fn func(Entry) error) ([]TornLine, error) {
+	return scanAllLines(path, func(_ string, _ int, _ []byte, e Entry) error {
+		Upgrade(&e)
+		return fn(e)
+	})
+}
+
+// scanAllLines is ScanAll without the upgrade, also passing the file, line
+// number and raw JSON of each entry, as written.
+func scanAllLines(path string, fn func(file string, lineNo int, raw []byte, e Entry) error) ([]TornLine, error) {
+	files, err := ledgerFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var torn []TornLine
+	for _, file := range files {
+		t, err := scanLines(file, func(lineNo int, raw []byte, e Entry) error {
+			return fn(file, lineNo, raw, e)
+		})
+		torn = append(torn, t...)
+		if err != nil {
+			return torn, err
+		}
+	}
+	return torn, nil
+}
+
+// ledgerFiles returns the rotated segments of the ledger at path followed by
+// the active file. The active file is listed even if missing when there are
+// no segments, so that reading reports it.
+func ledgerFiles(path string) ([]string, error) {
+	files, err := Segments(path)
+	if err != nil {
+		return nil, err
+	}
+	if exists(path) || len(files) == 0 {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// splitLedgerPath splits "dir/plarix-ledger.jsonl" into its directory,
+// stem ("plarix-ledger") and extension (".jsonl").
+func splitLedgerPath(path string) (dir, stem, ext string) {
+	base := filepath.Base(path)
+	ext = filepath.Ext(base)
+	return filepath.Dir(path), strings.TrimSuffix(base, ext), ext
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,339 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock for Options.now that callers can advance.
+func fakeClock(start time.Time) (func() time.Time, func(time.Duration)) {
+	now := start
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func writeModels(t *testing.T, w *Writer, models ...string) {
+	t.Helper()
+	for _, m := range models {
+		if err := w.Write(Entry{Provider: "openai", Model: m, CostKnown: true}); err != nil {
+			t.Fatalf("Write(%s) failed: %v", m, err)
+		}
+	}
+}
+
+func readModels(t *testing.T, path string) string {
+	t.Helper()
+	entries, err := ReadAll(path)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	models := make([]string, len(entries))
+	for i, e := range entries {
+		models[i] = e.Model
+	}
+	return strings.Join(models, ",")
+}
+
+func TestWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+
+	// Each entry is over 75 bytes, so every write after the first starts a
+	// new segment.
+	w, err := OpenWriter(path, Options{MaxBytes: 150, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a", "b", "c", "d", "e")
+	w.Close()
+
+	segments, err := Segments(path)
+	if err != nil {
+		t.Fatalf("Segments failed: %v", err)
+	}
+	if len(segments) != 4 {
+		t.Fatalf("got %d segments, want 4: %v", len(segments), segments)
+	}
+	// Same start second: collisions get a sequence suffix, in order.
+	want := []string{
+		"plarix-ledger-20261018T093000Z.jsonl",
+		"plarix-ledger-20261018T093000Z.1.jsonl",
+		"plarix-ledger-20261018T093000Z.2.jsonl",
+		"plarix-ledger-20261018T093000Z.3.jsonl",
+	}
+	for i, s := range segments {
+		if filepath.Base(s) != want[i] {
+			t.Errorf("segment %d = %s, want %s", i, filepath.Base(s), want[i])
+		}
+	}
+
+	if got := readModels(t, path); got != "a,b,c,d,e" {
+		t.Errorf("ReadAll models = %s, want a,b,c,d,e", got)
+	}
+}
+
+func TestWriterRotatesDailyAndCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	now, advance := fakeClock(time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC))
+
+	w, err := OpenWriter(path, Options{Interval: RotateDaily, Compress: true, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a", "b")
+	advance(3 * time.Hour) // 2026-10-18 01:00
+	writeModels(t, w, "c")
+	advance(time.Hour)
+	writeModels(t, w, "d")
+	w.Close()
+
+	segments, _ := Segments(path)
+	if len(segments) != 1 || filepath.Base(segments[0]) != "plarix-ledger-20261017T000000Z.jsonl.gz" {
+		t.Fatalf("segments = %v, want [plarix-ledger-20261017T000000Z.jsonl.gz]", segments)
+	}
+	if got := readModels(t, path); got != "a,b,c,d" {
+		t.Errorf("ReadAll models = %s, want a,b,c,d", got)
+	}
+}
+
+func TestWriterReportsCompressErrorOnNextWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plarix-ledger.jsonl")
+	now, advance := fakeClock(time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC))
+
+	// A directory in the way of the temporary file makes compression fail.
+	if err := os.Mkdir(filepath.Join(dir, "plarix-ledger-20261017T000000Z.jsonl.gz.tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenWriter(path, Options{Interval: RotateDaily, Compress: true, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a")
+	advance(3 * time.Hour)
+	writeModels(t, w, "b") // rotates; compression fails in the background
+	w.maintWG.Wait()
+
+	if err := w.Write(Entry{Model: "c"}); err == nil || !strings.Contains(err.Error(), "compress") {
+		t.Errorf("Write error = %v, want the compression error", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close error = %v, want nil once reported", err)
+	}
+	if got := readModels(t, path); got != "a,b,c" {
+		t.Errorf("ReadAll models = %s, want a,b,c", got)
+	}
+}
+
+func TestWriterKeepsWritingWhenRotationCannotOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+
+	failed := false
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if !failed {
+			failed = true
+			return nil, os.ErrPermission
+		}
+		return os.OpenFile(name, flag, perm)
+	}
+	defer func() { openFile = os.OpenFile }()
+
+	w, err := OpenWriter(path, Options{MaxBytes: 150, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a")
+	if err := w.Write(Entry{Model: "b"}); err == nil {
+		t.Error("Write returned no error for the failed rotation")
+	}
+	writeModels(t, w, "c")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if got := readModels(t, path); got != "a,b,c" {
+		t.Errorf("ReadAll models = %s, want a,b,c", got)
+	}
+}
+
+func TestWriterKeepsWritingWhenRotationCannotClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+
+	w, err := OpenWriter(path, Options{MaxBytes: 150, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a")
+	// Closing the handle underneath the writer makes the rotation's Close
+	// fail.
+	w.file.Close()
+	if err := w.Write(Entry{Model: "b"}); err == nil {
+		t.Error("Write returned no error for the failed rotation")
+	}
+	writeModels(t, w, "c", "d")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if got := readModels(t, path); got != "a,b,c,d" {
+		t.Errorf("ReadAll models = %s, want a,b,c,d", got)
+	}
+	if segments, _ := Segments(path); len(segments) == 0 {
+		t.Error("no segment: rotation did not resume after the failed close")
+	}
+}
+
+func TestWriterRotatesStaleFileOnReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	old := `{"ts":"2026-10-16T08:00:00Z","provider":"openai","model":"old","cost_known":true,"streaming":false}` + "\n"
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now, _ := fakeClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	w, err := OpenWriter(path, Options{Interval: RotateDaily, now: now})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "new")
+	w.Close()
+
+	segments, _ := Segments(path)
+	if len(segments) != 1 || filepath.Base(segments[0]) != "plarix-ledger-20261016T000000Z.jsonl" {
+		t.Fatalf("segments = %v, want [plarix-ledger-20261016T000000Z.jsonl]", segments)
+	}
+	if got := readModels(t, path); got != "old,new" {
+		t.Errorf("ReadAll models = %s, want old,new", got)
+	}
+}
+
+func TestWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plarix-ledger.jsonl")
+	now, advance := fakeClock(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+
+	// A segment from a previous run, well past MaxAge.
+	ancient := filepath.Join(dir, "plarix-ledger-20260101T000000Z.jsonl")
+	if err := os.WriteFile(ancient, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(ancient, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenWriter(path, Options{
+		Interval:    RotateHourly,
+		MaxAge:      30 * 24 * time.Hour,
+		MaxSegments: 2,
+		now:         now,
+	})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	if _, err := os.Stat(ancient); !os.IsNotExist(err) {
+		t.Errorf("segment older than MaxAge was not removed on open")
+	}
+
+	for _, m := range []string{"a", "b", "c", "d", "e"} {
+		writeModels(t, w, m)
+		advance(time.Hour)
+	}
+	w.Close()
+
+	segments, _ := Segments(path)
+	if len(segments) != 2 {
+		t.Fatalf("got %d segments, want 2: %v", len(segments), segments)
+	}
+	if got := readModels(t, path); got != "c,d,e" {
+		t.Errorf("ReadAll models = %s, want c,d,e", got)
+	}
+}
+
+func TestReadAllMissing(t *testing.T) {
+	_, err := ReadAll(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if !os.IsNotExist(err) {
+		t.Errorf("ReadAll(missing) error = %v, want not-exist", err)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"daily", RotateDaily, false},
+		{"Hourly", RotateHourly, false},
+		{"6h", 6 * time.Hour, false},
+		{"10s", 0, true},
+		{"weekly", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseInterval(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseInterval(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1048576", 1 << 20, false},
+		{"512K", 512 << 10, false},
+		{"100MB", 100 << 20, false},
+		{"2 gb", 2 << 30, false},
+		{"0", 0, true},
+		{"lots", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
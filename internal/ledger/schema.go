@@ -0,0 +1,193 @@
+package ledger
+
+import (
+	"bufio"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SchemaVersion is the version of the Entry format written by this release.
+//
+// Version 1 is the format of releases up to 0.6.0, whose lines carry no
+// schema_version. Version 2 adds schema_version and cost_source (plus the
+// optional pricing, batch, provider-cost and hash-chain fields) and writes
+// costs as exact decimals.
+const SchemaVersion = 2
+
+// Schema is the JSON Schema of a ledger line at SchemaVersion.
+//
+//go:embed schema.json
+var Schema []byte
+
+// Upgrade brings an entry read from an older ledger up to SchemaVersion
+// and reports whether it changed. Entries from newer releases are left as is.
+func Upgrade(e *Entry) bool {
+	if e.SchemaVersion >= SchemaVersion {
+		return false
+	}
+	// Version 1: every known cost came from the pricing table.
+	if e.CostKnown && e.CostSource == "" {
+		e.CostSource = CostSourcePricingTable
+	}
+	e.SchemaVersion = SchemaVersion
+	return true
+}
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	// Out writes all entries, oldest first, to this single new file instead
+	// of rewriting the ledger and its rotated segments in place.
+	Out string
+
+	// Chain recomputes the hash chain over the migrated lines (HMAC-SHA256
+	// with HMACKey if set). Without it prev_hash is dropped, since rewritten
+	// lines no longer match their old hashes.
+	Chain   bool
+	HMACKey []byte
+}
+
+// MigrateResult reports what Migrate did.
+type MigrateResult struct {
+	Files    int
+	Entries  int
+	Upgraded int
+	Torn     []TornLine
+}
+
+// Migrate rewrites the ledger at path and its rotated segments in the
+// current schema, dropping torn lines. Files are replaced one at a time via
+// a temporary file; as Migrate is idempotent, an interrupted run can simply
+// be repeated. The ledger must not be written to while it runs.
+func Migrate(path string, opts MigrateOptions) (MigrateResult, error) {
+	var r MigrateResult
+	files, err := ledgerFiles(path)
+	if err != nil {
+		return r, err
+	}
+
+	var single *migrateOutput
+	if opts.Out != "" {
+		if single, err = createMigrateOutput(opts.Out + ".migrate.tmp"); err != nil {
+			return r, err
+		}
+		defer single.abort()
+	}
+
+	prev := chainHash(opts.HMACKey, nil)
+	for _, file := range files {
+		out := single
+		if out == nil {
+			if out, err = createMigrateOutput(file + ".migrate.tmp"); err != nil {
+				return r, err
+			}
+			defer out.abort()
+		}
+
+		torn, err := scanLines(file, func(lineNo int, _ []byte, e Entry) error {
+			if e.SchemaVersion > SchemaVersion {
+				return fmt.Errorf("%s:%d: schema version %d is newer than this release supports (%d)",
+					file, lineNo, e.SchemaVersion, SchemaVersion)
+			}
+			r.Entries++
+			if Upgrade(&e) {
+				r.Upgraded++
+			}
+			e.PrevHash = ""
+			if opts.Chain {
+				e.PrevHash = prev
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			prev = chainHash(opts.HMACKey, data)
+			return out.write(append(data, '\n'))
+		})
+		r.Torn = append(r.Torn, torn...)
+		if err != nil {
+			return r, err
+		}
+		r.Files++
+
+		if single == nil {
+			if err := out.commit(file); err != nil {
+				return r, fmt.Errorf("replace %s: %w", file, err)
+			}
+		}
+	}
+
+	if single != nil {
+		if err := single.commit(opts.Out); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// migrateOutput is a temporary file receiving migrated lines, gzipped when
+// its final name ends in .gz.
+type migrateOutput struct {
+	tmp  string
+	file *os.File
+	buf  *bufio.Writer
+	zw   *gzip.Writer
+	done bool
+}
+
+func createMigrateOutput(tmp string) (*migrateOutput, error) {
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	o := &migrateOutput{tmp: tmp, file: f}
+	var w io.Writer = f
+	if strings.HasSuffix(strings.TrimSuffix(tmp, ".migrate.tmp"), ".gz") {
+		o.zw = gzip.NewWriter(f)
+		w = o.zw
+	}
+	o.buf = bufio.NewWriter(w)
+	return o, nil
+}
+
+func (o *migrateOutput) write(line []byte) error {
+	_, err := o.buf.Write(line)
+	return err
+}
+
+// commit flushes, syncs and renames the output to dst.
+func (o *migrateOutput) commit(dst string) error {
+	err := o.buf.Flush()
+	if o.zw != nil {
+		if cerr := o.zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if serr := o.file.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := o.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(o.tmp, dst)
+	}
+	o.done = true
+	if err != nil {
+		os.Remove(o.tmp)
+	}
+	return err
+}
+
+// abort discards an output that was not committed.
+func (o *migrateOutput) abort() {
+	if !o.done {
+		o.file.Close()
+		os.Remove(o.tmp)
+		o.done = true
+	}
+}
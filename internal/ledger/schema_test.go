@@ -0,0 +1,231 @@
+package ledger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSchemaMatchesEntry(t *testing.T) {
+	var schema struct {
+		Properties map[string]struct {
+			Const *int `json:"const"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema.json is not valid JSON: %v", err)
+	}
+
+	var fields, required []string
+	typ := reflect.TypeOf(Entry{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("Entry field %q is missing from schema.json", name)
+		}
+	}
+	if len(schema.Properties) != len(fields) {
+		for name := range schema.Properties {
+			if !contains(fields, name) {
+				t.Errorf("schema.json property %q is not an Entry field", name)
+			}
+		}
+	}
+
+	sort.Strings(required)
+	sort.Strings(schema.Required)
+	if !reflect.DeepEqual(required, schema.Required) {
+		t.Errorf("schema required = %v, want the non-omitempty fields %v", schema.Required, required)
+	}
+
+	if v := schema.Properties["schema_version"].Const; v == nil || *v != SchemaVersion {
+		t.Errorf("schema_version const = %v, want %d", v, SchemaVersion)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantChanged bool
+		wantSource  string
+	}{
+		{"v1 priced", `{"ts":"2026-01-04T12:00:00Z","provider":"openai","model":"gpt-4o","cost_usd":0.001325,"cost_known":true}`, true, CostSourcePricingTable},
+		{"v1 unknown", `{"ts":"2026-01-04T12:00:00Z","provider":"openai","model":"x","cost_known":false,"unknown_reason":"model not found"}`, true, ""},
+		{"current", `{"schema_version":2,"ts":"2026-10-18T12:00:00Z","provider":"openrouter","model":"m","cost_known":true,"cost_source":"provider"}`, false, CostSourceProvider},
+	}
+	for _, tt := range tests {
+		var e Entry
+		if err := json.Unmarshal([]byte(tt.line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if got := Upgrade(&e); got != tt.wantChanged {
+			t.Errorf("%s: Upgrade = %v, want %v", tt.name, got, tt.wantChanged)
+		}
+		if e.SchemaVersion != SchemaVersion {
+			t.Errorf("%s: SchemaVersion = %d, want %d", tt.name, e.SchemaVersion, SchemaVersion)
+		}
+		if e.CostSource != tt.wantSource {
+			t.Errorf("%s: CostSource = %q, want %q", tt.name, e.CostSource, tt.wantSource)
+		}
+	}
+}
+
+// writeLegacyLedger writes a version 1 ledger with a gzipped rotated segment,
+// a plain segment and an active file ending in a torn line.
+func writeLegacyLedger(t *testing.T, dir string) string {
+	t.Helper()
+	line := func(model string) string {
+		return `{"ts":"2026-01-04T12:00:00Z","provider":"openai","endpoint":"/v1/chat/completions","model":"` +
+			model + `","cost_usd":0.001325,"cost_known":true,"streaming":false}` + "\n"
+	}
+
+	f, err := os.Create(filepath.Join(dir, "plarix-ledger-20260104T000000Z.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(line("a") + line("b")))
+	zw.Close()
+	f.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "plarix-ledger-20260105T000000Z.jsonl"), []byte(line("c")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plarix-ledger.jsonl")
+	if err := os.WriteFile(path, []byte(line("d")+`{"ts":"2026-01-06`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanAllUpgradesSegments(t *testing.T) {
+	path := writeLegacyLedger(t, t.TempDir())
+
+	var models []string
+	torn, err := ScanAll(path, func(e Entry) error {
+		if e.SchemaVersion != SchemaVersion || e.CostSource != CostSourcePricingTable {
+			t.Errorf("%s: v=%d src=%q, want v=%d src=%q", e.Model, e.SchemaVersion, e.CostSource, SchemaVersion, CostSourcePricingTable)
+		}
+		models = append(models, e.Model)
+		return nil
+	})
+	if err != nil || len(torn) != 1 {
+		t.Fatalf("ScanAll = %d torn, %v; want 1 torn line", len(torn), err)
+	}
+	if got := strings.Join(models, ","); got != "a,b,c,d" {
+		t.Errorf("models = %s, want a,b,c,d", got)
+	}
+
+	// The files themselves are not rewritten.
+	_, err = scanAllLines(path, func(file string, lineNo int, raw []byte, e Entry) error {
+		if e.SchemaVersion != 0 || strings.Contains(string(raw), "schema_version") {
+			t.Errorf("%s:%d rewritten: %s", file, lineNo, raw)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateInPlace(t *testing.T) {
+	path := writeLegacyLedger(t, t.TempDir())
+
+	r, err := Migrate(path, MigrateOptions{Chain: true})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if r.Files != 3 || r.Entries != 4 || r.Upgraded != 4 || len(r.Torn) != 1 {
+		t.Errorf("Migrate = %+v, want 3 files, 4 entries upgraded, 1 torn line", r)
+	}
+
+	segments, _ := Segments(path)
+	if len(segments) != 2 || !strings.HasSuffix(segments[0], ".jsonl.gz") {
+		t.Errorf("segments after migrate = %v, want the original two", segments)
+	}
+	if got := readModels(t, path); got != "a,b,c,d" {
+		t.Errorf("models = %s, want a,b,c,d", got)
+	}
+
+	_, err = scanAllLines(path, func(file string, lineNo int, raw []byte, e Entry) error {
+		if !strings.HasPrefix(string(raw), `{"schema_version":2,`) {
+			t.Errorf("%s:%d not migrated: %s", file, lineNo, raw)
+		}
+		if !strings.Contains(string(raw), `"cost_usd":0.001325,`) {
+			t.Errorf("%s:%d cost not preserved exactly: %s", file, lineNo, raw)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Verify(path, nil); err != nil || !v.OK() || v.Chained != 4 {
+		t.Errorf("Verify after migrate = %+v, %v; want 4 chained entries", v, err)
+	}
+
+	// Migrating again is a no-op for the entries.
+	if r, err := Migrate(path, MigrateOptions{Chain: true}); err != nil || r.Upgraded != 0 {
+		t.Errorf("second Migrate = %+v, %v; want nothing upgraded", r, err)
+	}
+}
+
+func TestMigrateToSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLegacyLedger(t, dir)
+	out := filepath.Join(dir, "migrated.jsonl")
+
+	if _, err := Migrate(path, MigrateOptions{Out: out}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	entries, err := ReadFile(out)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("ReadFile(out) = %d entries, %v; want 4", len(entries), err)
+	}
+	if entries[0].Model != "a" || entries[3].Model != "d" || entries[0].PrevHash != "" {
+		t.Errorf("migrated entries out of order or unexpectedly chained: %+v", entries)
+	}
+
+	// The source ledger is untouched.
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "schema_version") {
+		t.Error("Migrate with Out rewrote the source ledger")
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	line := `{"schema_version":99,"ts":"2030-01-01T00:00:00Z","provider":"openai","model":"m","cost_known":false,"streaming":false}` + "\n"
+	if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Migrate(path, MigrateOptions{})
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Migrate error = %v, want newer-schema error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != line {
+		t.Error("Migrate modified a ledger it rejected")
+	}
+	if matches, _ := filepath.Glob(path + "*.tmp"); len(matches) != 0 {
+		t.Errorf("Migrate left temporary files: %v", matches)
+	}
+}
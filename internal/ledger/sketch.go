@@ -0,0 +1,101 @@
+package ledger
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchAccuracy is the relative error of quantiles reported by a
+// quantileSketch.
+const sketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// quantileSketch estimates quantiles of a stream of positive values in
+// bounded memory. Values are counted in logarithmic buckets (as in
+// DDSketch), so any quantile is within sketchAccuracy of a true value and
+// the number of buckets grows with the log of the value range, not with the
+// number of values: about 700 buckets cover 1 ms to 1000 s.
+type quantileSketch struct {
+	buckets map[int]uint64
+	count   uint64
+	max     float64
+}
+
+func (s *quantileSketch) add(v float64) {
+	if v <= 0 {
+		return
+	}
+	if s.buckets == nil {
+		s.buckets = make(map[int]uint64)
+	}
+	s.buckets[int(math.Ceil(math.Log(v)/sketchLogGamma))]++
+	s.count++
+	if v > s.max {
+		s.max = v
+	}
+}
+
+// quantiles returns the estimated value at each quantile in qs (ascending,
+// between 0 and 1).
+func (s *quantileSketch) quantiles(qs ...float64) []float64 {
+	out := make([]float64, len(qs))
+	if s.count == 0 {
+		return out
+	}
+	keys := make([]int, 0, len(s.buckets))
+	for k := range s.buckets {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var seen uint64
+	i := 0
+	for _, k := range keys {
+		seen += s.buckets[k]
+		// The midpoint of bucket k, (gamma^(k-1), gamma^k], in relative terms.
+		v := 2 * math.Pow(sketchGamma, float64(k)) / (sketchGamma + 1)
+		if v > s.max {
+			v = s.max
+		}
+		for ; i < len(qs) && float64(seen) >= rank(qs[i], s.count); i++ {
+			out[i] = v
+		}
+	}
+	for ; i < len(qs); i++ {
+		out[i] = s.max
+	}
+	return out
+}
+
+// rank is the 1-based nearest rank of quantile q among n values.
+func rank(q float64, n uint64) float64 {
+	r := math.Ceil(q * float64(n))
+	if r < 1 {
+		r = 1
+	}
+	return r
+}
+
+// percentiles summarizes the sketch, or returns nil if it is empty.
+func (s *quantileSketch) percentiles() *Percentiles {
+	if s.count == 0 {
+		return nil
+	}
+	q := s.quantiles(0.5, 0.9, 0.99)
+	return &Percentiles{
+		Count: int(s.count),
+		P50:   roundMS(q[0]),
+		P90:   roundMS(q[1]),
+		P99:   roundMS(q[2]),
+		Max:   roundMS(s.max),
+	}
+}
+
+// roundMS rounds an estimate to a tenth of a millisecond.
+func roundMS(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store is a ledger that records entries and answers queries over them.
+// The JSONL Writer is a Store that scans its files; a DB keeps indexes.
+type Store interface {
+	Write(e Entry) error
+	// Query calls fn for each entry matching q. Returning an error from fn
+	// stops the query with that error.
+	Query(q Query, fn func(Entry) error) error
+	Close() error
+}
+
+var (
+	_ Store = (*Writer)(nil)
+	_ Store = (*DB)(nil)
+)
+
+// Query selects ledger entries. Zero fields match every entry.
+type Query struct {
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+	Provider string
+	Model    string
+	Tags     map[string]string // every tag must be present with this value
+}
+
+// Match reports whether e is selected by q. Entries whose timestamp cannot
+// be parsed never match a time range.
+func (q Query) Match(e Entry) bool {
+	if q.Provider != "" && e.Provider != q.Provider {
+		return false
+	}
+	if q.Model != "" && e.Model != q.Model {
+		return false
+	}
+	for k, v := range q.Tags {
+		if got, ok := e.Tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	if q.Since.IsZero() && q.Until.IsZero() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		return false
+	}
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// ParseTime parses a query bound: an RFC 3339 timestamp or a YYYY-MM-DD
+// date, taken as midnight UTC. An empty string is the zero time (no bound).
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want RFC 3339 or YYYY-MM-DD)", s)
+}
+
+// Query scans the ledger and its rotated segments in ledger order, skipping
+// truncated lines. Entries in a segment being rotated during the scan may be
+// missed.
+func (w *Writer) Query(q Query, fn func(Entry) error) error {
+	_, err := ScanAll(w.path, func(e Entry) error {
+		if !q.Match(e) {
+			return nil
+		}
+		return fn(e)
+	})
+	return err
+}
@@ -0,0 +1,213 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeEntries are written an hour apart from 2026-10-18T00:00:00Z, with the
+// 03:00 entry written last to exercise out-of-order timestamps.
+var storeEntries = []Entry{
+	{Timestamp: "2026-10-18T00:00:00Z", Provider: "openai", Model: "gpt-4o", Tags: map[string]string{"team": "search"}},
+	{Timestamp: "2026-10-18T01:00:00Z", Provider: "openai", Model: "gpt-4o-mini", Tags: map[string]string{"team": "ads"}},
+	{Timestamp: "2026-10-18T02:00:00Z", Provider: "anthropic", Model: "claude-x", Tags: map[string]string{"team": "search", "env": "prod"}},
+	{Timestamp: "2026-10-18T04:00:00Z", Provider: "openai", Model: "gpt-4o", Tags: map[string]string{"team": "search", "env": "prod"}},
+	{Timestamp: "2026-10-18T03:00:00Z", Provider: "openai", Model: "gpt-4o"},
+}
+
+// queryTimes runs q and returns the hour of each result's timestamp.
+func queryTimes(t *testing.T, s Store, q Query) string {
+	t.Helper()
+	var hours []string
+	err := s.Query(q, func(e Entry) error {
+		hours = append(hours, e.Timestamp[11:13])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return strings.Join(hours, ",")
+}
+
+func at(hour int) time.Time {
+	return time.Date(2026, 10, 18, hour, 0, 0, 0, time.UTC)
+}
+
+var storeQueries = []struct {
+	name  string
+	q     Query
+	db    string // DB results, in time order
+	jsonl string // Writer results, in ledger order
+}{
+	{"all", Query{}, "00,01,02,03,04", "00,01,02,04,03"},
+	{"since", Query{Since: at(2)}, "02,03,04", "02,04,03"},
+	{"range", Query{Since: at(1), Until: at(3)}, "01,02", "01,02"},
+	{"provider", Query{Provider: "openai"}, "00,01,03,04", "00,01,04,03"},
+	{"model and time", Query{Model: "gpt-4o", Until: at(4)}, "00,03", "00,03"},
+	{"tag", Query{Tags: map[string]string{"team": "search"}}, "00,02,04", "00,02,04"},
+	{"tags", Query{Provider: "openai", Tags: map[string]string{"team": "search", "env": "prod"}}, "04", "04"},
+	{"no match", Query{Model: "gpt-5"}, "", ""},
+}
+
+func TestDBQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.jsonl")
+	db, err := OpenDB(path, Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	for _, e := range storeEntries {
+		if err := db.Write(e); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for _, tt := range storeQueries {
+		if got := queryTimes(t, db, tt.q); got != tt.db {
+			t.Errorf("%s: Query = %q, want %q", tt.name, got, tt.db)
+		}
+	}
+}
+
+func TestWriterQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plarix-ledger.jsonl")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	defer w.Close()
+	for _, e := range storeEntries {
+		if err := w.Write(e); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for _, tt := range storeQueries {
+		if got := queryTimes(t, w, tt.q); got != tt.jsonl {
+			t.Errorf("%s: Query = %q, want %q", tt.name, got, tt.jsonl)
+		}
+	}
+}
+
+func TestDBReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.jsonl")
+	db, err := OpenDB(path, Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	for _, e := range storeEntries[:3] {
+		db.Write(e)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path + ".idx"); err != nil {
+		t.Fatalf("index not saved: %v", err)
+	}
+
+	// Entries appended behind the store's back, plus a torn line, are picked
+	// up on the next open.
+	w, _ := NewWriter(path)
+	for _, e := range storeEntries[3:] {
+		w.Write(e)
+	}
+	w.Close()
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"ts":"2026-10-18T05:00:00Z","provider":"op`)
+	f.Close()
+
+	ro, err := OpenDBReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenDBReadOnly failed: %v", err)
+	}
+	if got := queryTimes(t, ro, Query{Provider: "openai"}); got != "00,01,03,04" {
+		t.Errorf("read-only Query = %q, want 00,01,03,04", got)
+	}
+	if err := ro.Write(storeEntries[0]); err == nil {
+		t.Error("Write on a read-only store succeeded")
+	}
+	ro.Close()
+
+	// A stale index (data file replaced by a shorter one) is rebuilt.
+	os.WriteFile(path, []byte(`{"ts":"2026-10-18T06:00:00Z","provider":"openai","model":"gpt-4o"}`+"\n"), 0644)
+	db, err = OpenDB(path, Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if db.Len() != 1 {
+		t.Errorf("Len = %d, want 1", db.Len())
+	}
+	if got := queryTimes(t, db, Query{Model: "gpt-4o"}); got != "06" {
+		t.Errorf("Query after rebuild = %q, want 06", got)
+	}
+}
+
+func TestDBRebuildsRewrittenIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.jsonl")
+	db, err := OpenDB(path, Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	for _, e := range storeEntries[:3] {
+		db.Write(e)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Rewrite the file in place, keeping every line boundary, and grow it,
+	// as a migration might. Only the content tells the old index is stale.
+	data, _ := os.ReadFile(path)
+	rewritten := strings.ReplaceAll(string(data), `"provider":"openai"`, `"provider":"azure1"`)
+	rewritten += `{"ts":"2026-10-18T06:00:00Z","provider":"azure1","model":"gpt-4o"}` + "\n"
+	if err := os.WriteFile(path, []byte(rewritten), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := OpenDBReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenDBReadOnly failed: %v", err)
+	}
+	defer ro.Close()
+	if got := queryTimes(t, ro, Query{Provider: "openai"}); got != "" {
+		t.Errorf("Query(openai) = %q, want none after rewrite", got)
+	}
+	if got := queryTimes(t, ro, Query{Provider: "azure1"}); got != "00,01,06" {
+		t.Errorf("Query(azure1) = %q, want 00,01,06", got)
+	}
+}
+
+func TestOpenDBRejectsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.jsonl")
+	if _, err := OpenDB(path, Options{MaxBytes: 1 << 20}); err == nil {
+		t.Error("OpenDB with rotation succeeded")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2026-10-18", at(0), false},
+		{"2026-10-18T03:00:00Z", at(3), false},
+		{"2026-10-18T05:00:00+02:00", at(3), false},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTime(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
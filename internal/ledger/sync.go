@@ -0,0 +1,87 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SyncPolicy selects when a Writer flushes entries to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing to the operating system. A crash may lose
+	// recent entries or leave a torn last line.
+	SyncNever SyncPolicy = iota
+	// SyncInterval fsyncs pending writes every Options.SyncInterval.
+	SyncInterval
+	// SyncAlways fsyncs after every entry.
+	SyncAlways
+)
+
+// DefaultSyncInterval is used with SyncInterval when Options.SyncInterval is zero.
+const DefaultSyncInterval = time.Second
+
+// String returns the policy name accepted by ParseSyncPolicy.
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncInterval:
+		return "interval"
+	case SyncAlways:
+		return "always"
+	default:
+		return "never"
+	}
+}
+
+// ParseSyncPolicy parses "always", "interval" or "never".
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "always":
+		return SyncAlways, nil
+	case "interval":
+		return SyncInterval, nil
+	case "never", "":
+		return SyncNever, nil
+	}
+	return SyncNever, fmt.Errorf("invalid fsync policy %q (want always, interval or never)", s)
+}
+
+// startSyncLoop fsyncs the active file in the background while the writer
+// has unsynced entries. Errors are kept and returned by the next Write or
+// by Close.
+func (w *Writer) startSyncLoop() {
+	interval := w.opts.SyncInterval
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.mu.Lock()
+				if err := w.syncLocked(); err != nil && w.syncErr == nil {
+					w.syncErr = err
+				}
+				w.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// syncLocked fsyncs the active file if it has unsynced writes.
+// The caller must hold w.mu.
+func (w *Writer) syncLocked() error {
+	if !w.dirty || w.closed {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
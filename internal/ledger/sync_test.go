@@ -0,0 +1,168 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const goodLine = `{"ts":"2026-10-18T09:00:00Z","provider":"openai","model":"gpt-4o","cost_known":true,"streaming":false}`
+
+func TestParseSyncPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SyncPolicy
+		wantErr bool
+	}{
+		{"", SyncNever, false},
+		{"never", SyncNever, false},
+		{"Interval", SyncInterval, false},
+		{"always", SyncAlways, false},
+		{"sometimes", SyncNever, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSyncPolicy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSyncPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSyncPolicy(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriterSyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncNever, SyncInterval, SyncAlways} {
+		t.Run(policy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ledger.jsonl")
+			w, err := OpenWriter(path, Options{Sync: policy, SyncInterval: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("OpenWriter failed: %v", err)
+			}
+			writeModels(t, w, "a", "b")
+
+			if policy == SyncInterval {
+				deadline := time.Now().Add(2 * time.Second)
+				for {
+					w.mu.Lock()
+					dirty := w.dirty
+					w.mu.Unlock()
+					if !dirty {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatal("interval sync did not flush pending writes")
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			if policy == SyncAlways && w.dirty {
+				t.Error("SyncAlways left unsynced writes")
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			entries, err := ReadFile(path)
+			if err != nil || len(entries) != 2 {
+				t.Errorf("ReadFile = %d entries, %v; want 2 entries", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestScanFileSkipsTornLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantCount int
+		wantTorn  []int
+		wantErr   bool
+	}{
+		{"clean", goodLine + "\n" + goodLine + "\n", 2, nil, false},
+		{"unterminated last line", goodLine + "\n" + goodLine[:40], 1, []int{2}, false},
+		{"complete last line without newline", goodLine + "\n" + goodLine, 2, nil, false},
+		{"torn line followed by entries", goodLine + "\n" + goodLine[:40] + "\n" + goodLine + "\n", 2, []int{2}, false},
+		{"malformed complete line", goodLine + "\nnot json\n" + goodLine + "\n", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ledger.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			count := 0
+			torn, err := ScanFile(path, func(Entry) error {
+				count++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ScanFile error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), ":2:") {
+					t.Errorf("error %q does not name line 2", err)
+				}
+				return
+			}
+			if count != tt.wantCount {
+				t.Errorf("entries = %d, want %d", count, tt.wantCount)
+			}
+			if len(torn) != len(tt.wantTorn) {
+				t.Fatalf("torn = %v, want lines %v", torn, tt.wantTorn)
+			}
+			for i, line := range tt.wantTorn {
+				if torn[i].Line != line {
+					t.Errorf("torn[%d].Line = %d, want %d", i, torn[i].Line, line)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRepairsTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := os.WriteFile(path, []byte(goodLine+"\n"+goodLine[:40]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	writeModels(t, w, "after-crash")
+	w.Close()
+
+	var models []string
+	torn, err := ScanFile(path, func(e Entry) error {
+		models = append(models, e.Model)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanFile failed: %v", err)
+	}
+	if strings.Join(models, ",") != "gpt-4o,after-crash" {
+		t.Errorf("models = %v, want [gpt-4o after-crash]", models)
+	}
+	if len(torn) != 1 || torn[0].Line != 2 {
+		t.Errorf("torn = %v, want line 2", torn)
+	}
+}
+
+func TestWriterCloseTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	w, err := OpenWriter(path, Options{Sync: SyncInterval, SyncInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	writeModels(t, w, "a")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopCallsLimit is the number of slowest and most expensive calls a summary
+// lists.
+const TopCallsLimit = 10
+
+// topCalls keeps the TopCallsLimit highest-ranked entries seen, as a
+// min-heap so that the lowest-ranked kept entry is replaced first. Ties keep
+// the entry seen first.
+type topCalls struct {
+	rank    func(e *Entry) int64
+	entries []Entry
+}
+
+func (t *topCalls) Len() int           { return len(t.entries) }
+func (t *topCalls) Less(i, j int) bool { return t.rank(&t.entries[i]) < t.rank(&t.entries[j]) }
+func (t *topCalls) Swap(i, j int)      { t.entries[i], t.entries[j] = t.entries[j], t.entries[i] }
+func (t *topCalls) Push(x interface{}) { t.entries = append(t.entries, x.(Entry)) }
+func (t *topCalls) Pop() interface{} {
+	e := t.entries[len(t.entries)-1]
+	t.entries = t.entries[:len(t.entries)-1]
+	return e
+}
+
+// add offers e, which must have a positive rank.
+func (t *topCalls) add(e Entry) {
+	if len(t.entries) == TopCallsLimit && t.rank(&e) <= t.rank(&t.entries[0]) {
+		return
+	}
+	// Usage details and hash links are not needed to list a call.
+	e.RawUsage = nil
+	e.PrevHash = ""
+	if len(t.entries) < TopCallsLimit {
+		heap.Push(t, e)
+		return
+	}
+	t.entries[0] = e
+	heap.Fix(t, 0)
+}
+
+// sorted returns a copy of the kept entries, highest rank first; equal
+// ranks are ordered by timestamp.
+func (t *topCalls) sorted() []Entry {
+	if len(t.entries) == 0 {
+		return nil
+	}
+	out := append([]Entry(nil), t.entries...)
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := t.rank(&out[i]), t.rank(&out[j])
+		if ri != rj {
+			return ri > rj
+		}
+		return out[i].Timestamp < out[j].Timestamp
+	})
+	return out
+}
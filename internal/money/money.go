@@ -0,0 +1,239 @@
+// Package money provides exact fixed-point USD amounts.
+//
+// Purpose: Compute and aggregate costs without floating point drift.
+// Public API: USD, FromFloat, Parse, PerThousand
+// Usage: Price calls with PerThousand, sum USD values with +, and round only
+// when presenting (Format, Display).
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of units in one USD: amounts are whole picodollars
+// (1e-12 USD). Micro-dollars are too coarse for per-token prices (a token of
+// a $0.15 per 1M model costs 0.15 micro-dollars), whereas at this scale a
+// call's cost is exact whenever its price per 1K tokens has at most 9
+// decimals. An int64 holds up to about $9.2M.
+const Scale = 1_000_000_000_000
+
+// scaleDigits is log10(Scale).
+const scaleDigits = 12
+
+// USD is an amount of US dollars in picodollars.
+//
+// It marshals to JSON as an exact decimal number (e.g. 0.001325), so ledgers
+// remain readable by tools that expect a float, and unmarshals decimal text
+// without going through float64.
+type USD int64
+
+// FromFloat converts a float64 dollar amount, rounding to the nearest
+// picodollar. Decimal inputs with up to 12 fractional digits and 15
+// significant digits convert exactly.
+func FromFloat(f float64) USD {
+	return USD(math.Round(f * Scale))
+}
+
+// PerThousand returns the cost of n units at a price per 1000 units,
+// rounded half away from zero to the nearest picodollar.
+func PerThousand(n int, per1K USD) USD {
+	return divRound(int64(n)*int64(per1K), 1000)
+}
+
+// MulFloat scales the amount by a factor such as a service tier multiplier,
+// rounding to the nearest picodollar.
+func (a USD) MulFloat(f float64) USD {
+	if f == 1 {
+		return a
+	}
+	return USD(math.Round(float64(a) * f))
+}
+
+// Float64 returns the amount in dollars as a float64, for presentation.
+func (a USD) Float64() float64 {
+	return float64(a) / Scale
+}
+
+// Abs returns the absolute value.
+func (a USD) Abs() USD {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// String returns the exact decimal amount without trailing zeros, e.g. "0.001325".
+func (a USD) String() string {
+	s := a.Format(scaleDigits)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
+
+// Format renders the amount rounded (half away from zero) to the given
+// number of decimals, e.g. Format(4) = "0.0013".
+func (a USD) Format(decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+	if decimals > scaleDigits {
+		decimals = scaleDigits
+	}
+	v := divRound(int64(a), pow10(scaleDigits-decimals))
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	unit := pow10(decimals)
+	whole := int64(v) / unit
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, decimals, int64(v)%unit)
+}
+
+// Display renders the amount for reports: four decimals, extended to two
+// significant digits for small non-zero amounts so that sub-cent calls do
+// not show as 0.0000.
+func (a USD) Display() string {
+	decimals := 4
+	for decimals < scaleDigits && a != 0 && a.Abs() < USD(10*pow10(scaleDigits-decimals)) {
+		decimals++
+	}
+	return a.Format(decimals)
+}
+
+// Parse parses a decimal dollar amount such as "0.001325" or "1e-6" exactly;
+// digits beyond picodollars are rounded.
+func Parse(s string) (USD, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+		mantissa, exp = s[:i], e
+		// Larger exponents give the same result; bounding them keeps the
+		// shift below from overflowing.
+		if exp > math.MaxInt32 {
+			exp = math.MaxInt32
+		} else if exp < math.MinInt32 {
+			exp = math.MinInt32
+		}
+	}
+
+	neg := false
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg, mantissa = true, mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	// value = digits * 10^(exp - len(fracPart)); shift to picodollars.
+	shift := exp - len(fracPart) + scaleDigits
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return 0, nil
+	}
+
+	var v int64
+	var roundUp bool
+	if shift >= 0 {
+		if len(digits)+shift > 19 {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+		digits += strings.Repeat("0", shift)
+	} else {
+		cut := len(digits) + shift
+		if cut <= 0 {
+			roundUp = cut == 0 && digits[0] >= '5'
+			digits = ""
+		} else {
+			roundUp = digits[cut] >= '5'
+			digits = digits[:cut]
+		}
+	}
+	if digits != "" {
+		if len(digits) > 19 {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+		v = n
+	}
+	if roundUp {
+		v++
+	}
+	if neg {
+		v = -v
+	}
+	return USD(v), nil
+}
+
+// MarshalJSON encodes the amount as an exact JSON number.
+func (a USD) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number (or numeric string) exactly.
+func (a *USD) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// divRound divides rounding half away from zero.
+func divRound(n, d int64) USD {
+	q, r := n/d, n%d
+	if r < 0 {
+		r = -r
+	}
+	if 2*r >= d {
+		if n < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return USD(q)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
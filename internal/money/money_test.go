@@ -0,0 +1,126 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want USD
+	}{
+		{"0", 0},
+		{"1", Scale},
+		{"0.001325", 1325000000},
+		{"0.00015", 150000000},
+		{"2.5e-06", 2500000},
+		{"1E-12", 1},
+		{"-0.25", -250000000000},
+		{"0.0000000000015", 2}, // rounds half away from zero
+		{"0.0000000000004", 0},
+		{"12.000000000000", 12 * Scale},
+		{"1e-999999999", 0},
+		{"-5e-9223372036854775808", 0},
+		{"0e999999999", 0},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1.2.3", "1e", "99999999999", "1e999999999", "1e9223372036854775807"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestPerThousand(t *testing.T) {
+	// gpt-4o-mini input: $0.00015 per 1K tokens -> 1 token = $0.00000015 exactly.
+	per1K := FromFloat(0.00015)
+	if got := PerThousand(1, per1K); got.String() != "0.00000015" {
+		t.Errorf("PerThousand(1) = %s, want 0.00000015", got)
+	}
+
+	// The float64 sum of many tiny costs drifts; the fixed-point sum does not.
+	var total USD
+	var floatTotal float64
+	for i := 0; i < 100000; i++ {
+		total += PerThousand(7, per1K)
+		floatTotal += 7 * 0.00015 / 1000
+	}
+	if total.String() != "0.105" {
+		t.Errorf("total = %s, want exactly 0.105", total)
+	}
+	if floatTotal == 0.105 {
+		t.Log("float64 happened to be exact; fixed-point result checked above")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	a := USD(1325000000) // 0.001325
+	if got := a.Format(4); got != "0.0013" {
+		t.Errorf("Format(4) = %q, want 0.0013", got)
+	}
+	if got := USD(-15 * Scale / 10).Format(0); got != "-2" {
+		t.Errorf("Format(0) = %q, want -2", got)
+	}
+	if got := a.String(); got != "0.001325" {
+		t.Errorf("String() = %q, want 0.001325", got)
+	}
+	if got := USD(3 * Scale).String(); got != "3" {
+		t.Errorf("String() = %q, want 3", got)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	tests := []struct {
+		in   USD
+		want string
+	}{
+		{0, "0.0000"},
+		{FromFloat(1.23456), "1.2346"},
+		{FromFloat(0.0012), "0.0012"},
+		{FromFloat(0.0001), "0.00010"},
+		{FromFloat(0.0000123), "0.000012"},
+		{FromFloat(0.00000015), "0.00000015"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Display(); got != tt.want {
+			t.Errorf("Display(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	type record struct {
+		Cost USD  `json:"cost_usd"`
+		Ptr  *USD `json:"reported,omitempty"`
+	}
+
+	var r record
+	if err := json.Unmarshal([]byte(`{"cost_usd":0.000000123456789012,"reported":0}`), &r); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if r.Cost != 123457 {
+		t.Errorf("Cost = %d, want 123457", r.Cost)
+	}
+	if r.Ptr == nil || *r.Ptr != 0 {
+		t.Errorf("Ptr = %v, want pointer to 0", r.Ptr)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"cost_usd":0.000000123457,"reported":0}` {
+		t.Errorf("Marshal = %s", data)
+	}
+}
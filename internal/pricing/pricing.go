@@ -1,8 +1,11 @@
 // Package pricing handles LLM model pricing data.
 //
 // Purpose: Load pricing table, compute costs, check staleness.
-// Public API: Prices, Load, ComputeCost, IsStale
-// Usage: Load prices.json, then call ComputeCost for each model.
+// Public API: Prices, Load, Parse, Default, Overlay, Call, ComputeCost, ComputeCostAt,
+// ComputeCostFor, PriceAt, IsStale
+// Usage: Start from Default (or Load a custom table), layer overlays on top,
+// then call ComputeCostFor (or ComputeCost for a plain model/tokens pair) for
+// each recorded call.
 package pricing
 
 import (
@@ -10,27 +13,108 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"plarix-action/internal/money"
+	"plarix-action/prices"
 )
 
 // Prices holds the pricing table for all supported models.
 // It is intended to be loaded from a JSON file (prices.json).
 // AsOf indicates the date when this pricing snapshot was taken.
+// ServiceTiers holds default cost multipliers by service tier (see Call).
 type Prices struct {
-	AsOf   string                `json:"as_of"`
-	Models map[string]ModelPrice `json:"models"`
+	AsOf         string                `json:"as_of"`
+	Models       map[string]ModelPrice `json:"models"`
+	ServiceTiers map[string]float64    `json:"service_tiers,omitempty"`
+}
+
+// BatchTier is the ServiceTiers key applied to calls billed through a batch API.
+const BatchTier = "batch"
+
+// standardTiers are service tier names that bill at the model's list price.
+var standardTiers = map[string]bool{
+	"":         true,
+	"default":  true,
+	"standard": true,
+	"auto":     true,
 }
 
+// Call describes a single API call to be priced.
+//
+// ServiceTier is the tier reported by the provider (e.g. OpenAI "flex" or
+// "priority"); Batch marks calls made through a batch API. Both select a
+// multiplier from the model's ServiceTiers, falling back to the table-wide
+// ServiceTiers. At is the time of the call; zero means "now".
+type Call struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	At           time.Time
+	ServiceTier  string
+	Batch        bool
+}
+
+// dateLayout is the format of as_of and effective dates in prices.json.
+const dateLayout = "2006-01-02"
+
 // ModelPrice holds per-1K token prices for a model.
+//
+// EffectiveFrom (inclusive) and EffectiveTo (exclusive) bound the period in
+// which the price applied; an empty bound is open-ended. History holds the
+// prices that applied in other periods, so that old ledgers can be re-priced
+// with the rates that were in effect when the calls were made.
+//
+// Tiers lists alternative rates for long prompts; see PriceTier.
+// ServiceTiers overrides the table-wide service tier multipliers for this model.
 type ModelPrice struct {
-	InputPer1K  float64 `json:"input_per_1k"`
-	OutputPer1K float64 `json:"output_per_1k"`
+	InputPer1K    float64            `json:"input_per_1k"`
+	OutputPer1K   float64            `json:"output_per_1k"`
+	Tiers         []PriceTier        `json:"tiers,omitempty"`
+	ServiceTiers  map[string]float64 `json:"service_tiers,omitempty"`
+	EffectiveFrom string             `json:"effective_from,omitempty"`
+	EffectiveTo   string             `json:"effective_to,omitempty"`
+	History       []ModelPrice       `json:"history,omitempty"`
+}
+
+// PriceTier replaces a model's base rates for a whole call once its input
+// token count exceeds AboveInputTokens (e.g. long-context pricing above 200k).
+// When several tiers match, the one with the highest threshold wins.
+type PriceTier struct {
+	Name             string  `json:"name,omitempty"`
+	AboveInputTokens int     `json:"above_input_tokens"`
+	InputPer1K       float64 `json:"input_per_1k"`
+	OutputPer1K      float64 `json:"output_per_1k"`
+}
+
+// label returns the tier name recorded in ledger entries.
+func (t PriceTier) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("input>%d", t.AboveInputTokens)
+}
+
+// rates returns the per-1K rates for a call with the given input token count,
+// along with the applied tier label (empty for the base rate).
+func (mp ModelPrice) rates(inputTokens int) (inputPer1K, outputPer1K float64, tier string) {
+	inputPer1K, outputPer1K = mp.InputPer1K, mp.OutputPer1K
+	threshold := -1
+	for _, t := range mp.Tiers {
+		if inputTokens > t.AboveInputTokens && t.AboveInputTokens > threshold {
+			inputPer1K, outputPer1K, tier = t.InputPer1K, t.OutputPer1K, t.label()
+			threshold = t.AboveInputTokens
+		}
+	}
+	return inputPer1K, outputPer1K, tier
 }
 
 // CostResult holds the computed cost and status.
+// Tier names the PriceTier applied, or is empty for the base rate.
 type CostResult struct {
-	CostUSD       float64
+	CostUSD       money.USD
 	Known         bool
 	UnknownReason string
+	Tier          string
 }
 
 // Load reads and parses a pricing JSON file.
@@ -39,7 +123,16 @@ func Load(path string) (*Prices, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read pricing file: %w", err)
 	}
+	return Parse(data)
+}
+
+// Default returns the pricing table bundled into the binary.
+func Default() (*Prices, error) {
+	return Parse(prices.JSON)
+}
 
+// Parse parses a pricing table from JSON.
+func Parse(data []byte) (*Prices, error) {
 	var p Prices
 	if err := json.Unmarshal(data, &p); err != nil {
 		return nil, fmt.Errorf("parse pricing file: %w", err)
@@ -49,35 +142,223 @@ func Load(path string) (*Prices, error) {
 		p.Models = make(map[string]ModelPrice)
 	}
 
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("parse pricing file: %w", err)
+	}
+
 	return &p, nil
 }
 
-// ComputeCost calculates the cost for a model based on token counts.
+// Overlay layers another table on top of p. Models and service tier
+// multipliers in o add to or replace individual entries in p; everything
+// else in p is kept, including AsOf, which keeps describing the base table.
+func (p *Prices) Overlay(o *Prices) {
+	if p.Models == nil {
+		p.Models = make(map[string]ModelPrice)
+	}
+	for model, mp := range o.Models {
+		p.Models[model] = mp
+	}
+	if len(o.ServiceTiers) > 0 && p.ServiceTiers == nil {
+		p.ServiceTiers = make(map[string]float64)
+	}
+	for name, m := range o.ServiceTiers {
+		p.ServiceTiers[name] = m
+	}
+}
+
+// validate checks that all effective dates parse and form non-empty periods,
+// that tier thresholds are positive and distinct, and that multipliers are
+// not negative.
+func (p *Prices) validate() error {
+	for name, m := range p.ServiceTiers {
+		if m < 0 {
+			return fmt.Errorf("service tier %q: negative multiplier", name)
+		}
+	}
+	for model, mp := range p.Models {
+		periods := append([]ModelPrice{mp}, mp.History...)
+		for _, period := range periods {
+			from, to, err := period.bounds()
+			if err != nil {
+				return fmt.Errorf("model %q: %w", model, err)
+			}
+			if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+				return fmt.Errorf("model %q: effective_from %s is not before effective_to %s",
+					model, period.EffectiveFrom, period.EffectiveTo)
+			}
+			for name, m := range period.ServiceTiers {
+				if m < 0 {
+					return fmt.Errorf("model %q: service tier %q: negative multiplier", model, name)
+				}
+			}
+			seen := make(map[int]bool)
+			for _, t := range period.Tiers {
+				if t.AboveInputTokens <= 0 {
+					return fmt.Errorf("model %q: tier above_input_tokens must be positive", model)
+				}
+				if seen[t.AboveInputTokens] {
+					return fmt.Errorf("model %q: duplicate tier above %d input tokens", model, t.AboveInputTokens)
+				}
+				seen[t.AboveInputTokens] = true
+			}
+		}
+	}
+	return nil
+}
+
+// bounds parses the effective period. Zero times denote open bounds.
+func (mp ModelPrice) bounds() (from, to time.Time, err error) {
+	if mp.EffectiveFrom != "" {
+		if from, err = time.Parse(dateLayout, mp.EffectiveFrom); err != nil {
+			return from, to, fmt.Errorf("invalid effective_from %q", mp.EffectiveFrom)
+		}
+	}
+	if mp.EffectiveTo != "" {
+		if to, err = time.Parse(dateLayout, mp.EffectiveTo); err != nil {
+			return from, to, fmt.Errorf("invalid effective_to %q", mp.EffectiveTo)
+		}
+	}
+	return from, to, nil
+}
+
+// inEffect reports whether the price applied at the given instant.
+func (mp ModelPrice) inEffect(at time.Time) bool {
+	from, to, err := mp.bounds()
+	if err != nil {
+		return false
+	}
+	if !from.IsZero() && at.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !at.Before(to) {
+		return false
+	}
+	return true
+}
+
+// PriceAt returns the price of a model in effect at the given instant.
+// A zero time means "now". The returned price has no History.
+func (p *Prices) PriceAt(model string, at time.Time) (ModelPrice, bool) {
+	mp, ok := p.Models[model]
+	if !ok {
+		return ModelPrice{}, false
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	history := mp.History
+	mp.History = nil
+	if mp.inEffect(at) {
+		return mp, true
+	}
+	for _, h := range history {
+		if h.inEffect(at) {
+			h.History = nil
+			return h, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// ComputeCost calculates the cost for a model based on token counts,
+// using the price currently in effect.
 // Returns unknown if model is not in pricing table.
 //
 // Calculation: (InputTokens * InputPrice + OutputTokens * OutputPrice) / 1000
+// where the prices come from the highest tier the input token count exceeds.
 // We use 1k token granularity internally, even if pricing is gathered per 1M.
+// The arithmetic is fixed-point (see money.USD), so costs are exact.
 func (p *Prices) ComputeCost(model string, inputTokens, outputTokens int) CostResult {
-	mp, ok := p.Models[model]
-	if !ok {
+	return p.ComputeCostAt(model, time.Time{}, inputTokens, outputTokens)
+}
+
+// ComputeCostAt is like ComputeCost but uses the price in effect at the given
+// instant (typically the entry timestamp). A zero time means "now".
+func (p *Prices) ComputeCostAt(model string, at time.Time, inputTokens, outputTokens int) CostResult {
+	return p.ComputeCostFor(Call{
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		At:           at,
+	})
+}
+
+// ComputeCostFor prices a call with the rates in effect at c.At, scaled by
+// the multiplier for its batch or service tier.
+// Returns unknown if the model is not in the table, if no price for it was
+// in effect at that time, or if the table has no multiplier for a
+// non-standard service tier.
+func (p *Prices) ComputeCostFor(c Call) CostResult {
+	model, at, inputTokens, outputTokens := c.Model, c.At, c.InputTokens, c.OutputTokens
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if _, ok := p.Models[model]; !ok {
 		return CostResult{
 			Known:         false,
 			UnknownReason: fmt.Sprintf("model %q not in pricing table", model),
 		}
 	}
 
-	cost := (float64(inputTokens)*mp.InputPer1K + float64(outputTokens)*mp.OutputPer1K) / 1000.0
+	mp, ok := p.PriceAt(model, at)
+	if !ok {
+		return CostResult{
+			Known:         false,
+			UnknownReason: fmt.Sprintf("no price for model %q in effect on %s", model, at.UTC().Format(dateLayout)),
+		}
+	}
+
+	multiplier, ok := p.serviceTierMultiplier(mp, c)
+	if !ok {
+		return CostResult{
+			Known:         false,
+			UnknownReason: fmt.Sprintf("no price for service tier %q of model %q", c.tierName(), model),
+		}
+	}
+
+	inputPer1K, outputPer1K, tier := mp.rates(inputTokens)
+	cost := money.PerThousand(inputTokens, money.FromFloat(inputPer1K)) +
+		money.PerThousand(outputTokens, money.FromFloat(outputPer1K))
+	cost = cost.MulFloat(multiplier)
 
 	return CostResult{
 		CostUSD: cost,
 		Known:   true,
+		Tier:    tier,
+	}
+}
+
+// tierName returns the service tier a call is billed under.
+func (c Call) tierName() string {
+	if c.Batch {
+		return BatchTier
+	}
+	return c.ServiceTier
+}
+
+// serviceTierMultiplier looks up the cost multiplier for the call's tier,
+// preferring the model's own ServiceTiers over the table-wide defaults.
+// Standard tiers always bill at 1.0.
+func (p *Prices) serviceTierMultiplier(mp ModelPrice, c Call) (float64, bool) {
+	name := c.tierName()
+	if m, ok := mp.ServiceTiers[name]; ok {
+		return m, true
+	}
+	if m, ok := p.ServiceTiers[name]; ok {
+		return m, true
+	}
+	if standardTiers[name] {
+		return 1, true
 	}
+	return 0, false
 }
 
 // IsStale returns true if pricing data is older than the given duration.
 // Also returns true if as_of date cannot be parsed.
 func (p *Prices) IsStale(maxAge time.Duration) bool {
-	asOf, err := time.Parse("2006-01-02", p.AsOf)
+	asOf, err := time.Parse(dateLayout, p.AsOf)
 	if err != nil {
 		return true
 	}
@@ -41,6 +41,53 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestDefault(t *testing.T) {
+	p, err := Default()
+	if err != nil {
+		t.Fatalf("Default failed: %v", err)
+	}
+	if p.AsOf == "" {
+		t.Error("Expected bundled table to have as_of")
+	}
+	if _, ok := p.Models["gpt-4o"]; !ok {
+		t.Error("Expected bundled table to price gpt-4o")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	p := &Prices{
+		AsOf: "2026-01-01",
+		Models: map[string]ModelPrice{
+			"gpt-4o":      {InputPer1K: 0.0025, OutputPer1K: 0.01},
+			"gpt-4o-mini": {InputPer1K: 0.00015, OutputPer1K: 0.0006},
+		},
+	}
+	p.Overlay(&Prices{
+		AsOf: "2026-06-01",
+		Models: map[string]ModelPrice{
+			"gpt-4o":         {InputPer1K: 0.002, OutputPer1K: 0.008},
+			"my-finetune-v1": {InputPer1K: 0.003, OutputPer1K: 0.012},
+		},
+		ServiceTiers: map[string]float64{"batch": 0.5},
+	})
+
+	if p.AsOf != "2026-01-01" {
+		t.Errorf("AsOf = %q, want base table's 2026-01-01", p.AsOf)
+	}
+	if len(p.Models) != 3 {
+		t.Errorf("len(Models) = %d, want 3", len(p.Models))
+	}
+	if p.Models["gpt-4o"].InputPer1K != 0.002 {
+		t.Errorf("gpt-4o InputPer1K = %f, want overlay's 0.002", p.Models["gpt-4o"].InputPer1K)
+	}
+	if p.Models["gpt-4o-mini"].InputPer1K != 0.00015 {
+		t.Error("Expected gpt-4o-mini to be kept from base table")
+	}
+	if p.ServiceTiers["batch"] != 0.5 {
+		t.Errorf("ServiceTiers[batch] = %f, want 0.5", p.ServiceTiers["batch"])
+	}
+}
+
 func TestComputeCost(t *testing.T) {
 	p := &Prices{
 		AsOf: "2026-01-01",
@@ -55,9 +102,8 @@ func TestComputeCost(t *testing.T) {
 		t.Error("Expected cost to be known for gpt-4o")
 	}
 	// cost = (1000 * 0.0025 + 500 * 0.01) / 1000 = (2.5 + 5) / 1000 = 0.0075
-	expected := 0.0075
-	if r.CostUSD != expected {
-		t.Errorf("CostUSD = %f, want %f", r.CostUSD, expected)
+	if r.CostUSD.String() != "0.0075" {
+		t.Errorf("CostUSD = %s, want 0.0075", r.CostUSD)
 	}
 
 	// Unknown model
@@ -70,6 +116,159 @@ func TestComputeCost(t *testing.T) {
 	}
 }
 
+func TestComputeCostAt(t *testing.T) {
+	p := &Prices{
+		AsOf: "2026-01-01",
+		Models: map[string]ModelPrice{
+			"gpt-4o": {
+				InputPer1K:    0.0025,
+				OutputPer1K:   0.01,
+				EffectiveFrom: "2024-10-02",
+				History: []ModelPrice{
+					{InputPer1K: 0.005, OutputPer1K: 0.015, EffectiveFrom: "2024-05-13", EffectiveTo: "2024-10-02"},
+				},
+			},
+		},
+	}
+	if err := p.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantKnown bool
+		wantCost  string
+	}{
+		{"current price", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), true, "0.0125"},
+		{"first day of current price", time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC), true, "0.0125"},
+		{"historical price", time.Date(2024, 10, 1, 23, 59, 0, 0, time.UTC), true, "0.02"},
+		{"before any price", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false, "0"},
+		{"zero time means now", time.Time{}, true, "0.0125"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.ComputeCostAt("gpt-4o", tt.at, 1000, 1000)
+			if r.Known != tt.wantKnown {
+				t.Fatalf("Known = %v, want %v (reason %q)", r.Known, tt.wantKnown, r.UnknownReason)
+			}
+			if r.CostUSD.String() != tt.wantCost {
+				t.Errorf("CostUSD = %s, want %s", r.CostUSD, tt.wantCost)
+			}
+			if !r.Known && r.UnknownReason == "" {
+				t.Error("Expected UnknownReason to be set")
+			}
+		})
+	}
+}
+
+func TestComputeCostTiers(t *testing.T) {
+	p := &Prices{
+		AsOf: "2026-01-01",
+		Models: map[string]ModelPrice{
+			"claude-sonnet-4": {
+				InputPer1K:  0.003,
+				OutputPer1K: 0.015,
+				Tiers: []PriceTier{
+					{Name: "long-context", AboveInputTokens: 200000, InputPer1K: 0.006, OutputPer1K: 0.0225},
+					{AboveInputTokens: 100000, InputPer1K: 0.004, OutputPer1K: 0.02},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		input    int
+		wantTier string
+		wantCost string
+	}{
+		{"base rate", 1000, "", "0.018"},
+		{"at threshold stays on lower rate", 100000, "", "0.315"},
+		{"unnamed tier", 150000, "input>100000", "0.62"},
+		{"highest matching tier", 250000, "long-context", "1.5225"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.ComputeCost("claude-sonnet-4", tt.input, 1000)
+			if !r.Known {
+				t.Fatalf("Expected cost to be known: %s", r.UnknownReason)
+			}
+			if r.Tier != tt.wantTier {
+				t.Errorf("Tier = %q, want %q", r.Tier, tt.wantTier)
+			}
+			if r.CostUSD.String() != tt.wantCost {
+				t.Errorf("CostUSD = %s, want %s", r.CostUSD, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestComputeCostForServiceTiers(t *testing.T) {
+	p := &Prices{
+		AsOf: "2026-01-01",
+		Models: map[string]ModelPrice{
+			"gpt-4o": {
+				InputPer1K:   0.0025,
+				OutputPer1K:  0.01,
+				ServiceTiers: map[string]float64{"priority": 1.7},
+			},
+		},
+		ServiceTiers: map[string]float64{"batch": 0.5, "flex": 0.5},
+	}
+
+	// 1000 in + 1000 out = $0.0125 at list price
+	tests := []struct {
+		name      string
+		call      Call
+		wantKnown bool
+		wantCost  string
+	}{
+		{"default tier", Call{ServiceTier: "default"}, true, "0.0125"},
+		{"no tier", Call{}, true, "0.0125"},
+		{"batch", Call{Batch: true}, true, "0.00625"},
+		{"batch wins over reported tier", Call{Batch: true, ServiceTier: "default"}, true, "0.00625"},
+		{"table-wide flex", Call{ServiceTier: "flex"}, true, "0.00625"},
+		{"model priority override", Call{ServiceTier: "priority"}, true, "0.02125"},
+		{"unpriced tier", Call{ServiceTier: "scale"}, false, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.call
+			c.Model, c.InputTokens, c.OutputTokens = "gpt-4o", 1000, 1000
+			r := p.ComputeCostFor(c)
+			if r.Known != tt.wantKnown {
+				t.Fatalf("Known = %v, want %v (reason %q)", r.Known, tt.wantKnown, r.UnknownReason)
+			}
+			if r.CostUSD.String() != tt.wantCost {
+				t.Errorf("CostUSD = %s, want %s", r.CostUSD, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidEffectiveDates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prices.json")
+
+	content := `{
+  "as_of": "2026-01-01",
+  "models": {
+    "gpt-4o": { "input_per_1k": 0.0025, "output_per_1k": 0.01, "effective_from": "2025-01-01", "effective_to": "2024-01-01" }
+  }
+}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Expected error for effective_from after effective_to")
+	}
+}
+
 func TestIsStale(t *testing.T) {
 	// Recent date - not stale
 	p := &Prices{AsOf: time.Now().Format("2006-01-02")}
@@ -0,0 +1,308 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Price feed formats understood by ParseFeed.
+const (
+	FeedOpenRouter = "openrouter" // https://openrouter.ai/api/v1/models
+	FeedLiteLLM    = "litellm"    // model_prices_and_context_window.json
+)
+
+// feedTimeout bounds a price feed download.
+const feedTimeout = 30 * time.Second
+
+// FetchFeed reads a price feed from an http(s) URL or a local file.
+func FetchFeed(src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.ReadFile(src)
+	}
+
+	client := &http.Client{Timeout: feedTimeout}
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fetch feed failed: %s - %s", resp.Status, string(body))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ParseFeed converts a machine-readable price feed to per-1K model prices.
+// format is FeedOpenRouter, FeedLiteLLM, or "" to detect it from the data.
+// Models whose prices are missing or variable are skipped.
+func ParseFeed(data []byte, format string) (map[string]ModelPrice, error) {
+	if format == "" || format == "auto" {
+		format = detectFeed(data)
+	}
+	switch format {
+	case FeedOpenRouter:
+		return parseOpenRouterFeed(data)
+	case FeedLiteLLM:
+		return parseLiteLLMFeed(data)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+}
+
+// detectFeed tells the formats apart: OpenRouter wraps models in "data".
+func detectFeed(data []byte) string {
+	var probe struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && len(probe.Data) > 0 && probe.Data[0] == '[' {
+		return FeedOpenRouter
+	}
+	return FeedLiteLLM
+}
+
+// parseOpenRouterFeed parses the OpenRouter models listing, where prices are
+// decimal strings in USD per token.
+func parseOpenRouterFeed(data []byte) (map[string]ModelPrice, error) {
+	var feed struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Pricing struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("parse openrouter feed: %w", err)
+	}
+
+	models := make(map[string]ModelPrice)
+	for _, m := range feed.Data {
+		input, err1 := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		output, err2 := strconv.ParseFloat(m.Pricing.Completion, 64)
+		// Routers such as "openrouter/auto" report -1 (variable pricing).
+		if m.ID == "" || err1 != nil || err2 != nil || input < 0 || output < 0 {
+			continue
+		}
+		models[m.ID] = ModelPrice{
+			InputPer1K:  perTokenTo1K(input),
+			OutputPer1K: perTokenTo1K(output),
+		}
+	}
+	return models, nil
+}
+
+// liteLLMTierKey matches long-context keys such as
+// "input_cost_per_token_above_200k_tokens".
+var liteLLMTierKey = regexp.MustCompile(`^input_cost_per_token_above_(\d+)k_tokens$`)
+
+// parseLiteLLMFeed parses a LiteLLM-style model cost map: an object keyed by
+// model name with USD per-token costs, including long-context tiers.
+func parseLiteLLMFeed(data []byte) (map[string]ModelPrice, error) {
+	var feed map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("parse litellm feed: %w", err)
+	}
+
+	models := make(map[string]ModelPrice)
+	for name, spec := range feed {
+		if name == "sample_spec" {
+			continue
+		}
+		input, ok1 := spec["input_cost_per_token"].(float64)
+		output, ok2 := spec["output_cost_per_token"].(float64)
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		mp := ModelPrice{
+			InputPer1K:  perTokenTo1K(input),
+			OutputPer1K: perTokenTo1K(output),
+		}
+		for key, v := range spec {
+			match := liteLLMTierKey.FindStringSubmatch(key)
+			tierInput, ok := v.(float64)
+			if match == nil || !ok {
+				continue
+			}
+			k, _ := strconv.Atoi(match[1])
+			tierOutput, ok := spec[fmt.Sprintf("output_cost_per_token_above_%sk_tokens", match[1])].(float64)
+			if !ok {
+				tierOutput = output
+			}
+			mp.Tiers = append(mp.Tiers, PriceTier{
+				AboveInputTokens: k * 1000,
+				InputPer1K:       perTokenTo1K(tierInput),
+				OutputPer1K:      perTokenTo1K(tierOutput),
+			})
+		}
+		sort.Slice(mp.Tiers, func(i, j int) bool {
+			return mp.Tiers[i].AboveInputTokens < mp.Tiers[j].AboveInputTokens
+		})
+		models[name] = mp
+	}
+	return models, nil
+}
+
+// perTokenTo1K converts a per-token price to per-1K, dropping the binary
+// floating point noise the multiplication introduces (2.5e-06*1000 would
+// otherwise be written as 0.0025000000000000005).
+func perTokenTo1K(perToken float64) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(perToken*1000, 'g', 12, 64), 64)
+	return v
+}
+
+// Change describes a model whose price was added or changed by Sync.
+// Old is nil for added models.
+type Change struct {
+	Model string
+	Old   *ModelPrice
+	New   ModelPrice
+}
+
+// String renders the change as a diff line with prices per 1M tokens.
+func (c Change) String() string {
+	if c.Old == nil {
+		return fmt.Sprintf("+ %s: %s", c.Model, formatPer1M(c.New))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Model, formatPer1M(*c.Old), formatPer1M(c.New))
+}
+
+func formatPer1M(mp ModelPrice) string {
+	s := fmt.Sprintf("$%s / $%s per 1M", per1M(mp.InputPer1K), per1M(mp.OutputPer1K))
+	for _, t := range mp.Tiers {
+		s += fmt.Sprintf(" (%s: $%s / $%s)", t.label(), per1M(t.InputPer1K), per1M(t.OutputPer1K))
+	}
+	return s
+}
+
+func per1M(per1K float64) string {
+	return strconv.FormatFloat(perTokenTo1K(per1K), 'f', -1, 64)
+}
+
+// Sync updates p with the prices from a feed and sets AsOf to asOf.
+//
+// Models already in the table are updated when the feed's rates differ. The
+// replaced price is kept in History with EffectiveTo = asOf and the new price
+// takes EffectiveFrom = asOf, so ledgers from before the change still re-price
+// correctly. Service tier multipliers are kept, and so are tiers when the feed
+// has none for the model. Models only in the feed are added when addNew is set.
+// Changes are returned sorted by model name.
+func (p *Prices) Sync(feed map[string]ModelPrice, asOf time.Time, addNew bool) []Change {
+	if p.Models == nil {
+		p.Models = make(map[string]ModelPrice)
+	}
+	date := asOf.UTC().Format(dateLayout)
+
+	var changes []Change
+	for model, fp := range feed {
+		old, exists := p.Models[model]
+		if !exists {
+			if addNew {
+				p.Models[model] = fp
+				changes = append(changes, Change{Model: model, New: fp})
+			}
+			continue
+		}
+
+		updated := old
+		updated.InputPer1K = fp.InputPer1K
+		updated.OutputPer1K = fp.OutputPer1K
+		if len(fp.Tiers) > 0 {
+			updated.Tiers = withTierNames(fp.Tiers, old.Tiers)
+		}
+		if sameRates(old, updated) {
+			continue
+		}
+
+		previous := old
+		previous.History = nil
+		if old.EffectiveFrom == date {
+			// Re-synced on the day of the change: replace, don't record history.
+			updated.History = old.History
+		} else {
+			expired := previous
+			expired.EffectiveTo = date
+			updated.History = append([]ModelPrice{expired}, old.History...)
+		}
+		updated.EffectiveFrom = date
+		updated.EffectiveTo = ""
+		p.Models[model] = updated
+
+		changes = append(changes, Change{Model: model, Old: &previous, New: updated})
+	}
+
+	p.AsOf = date
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Model < changes[j].Model })
+	return changes
+}
+
+// withTierNames carries names over from existing tiers with the same threshold.
+func withTierNames(tiers, existing []PriceTier) []PriceTier {
+	named := make([]PriceTier, len(tiers))
+	for i, t := range tiers {
+		for _, e := range existing {
+			if e.AboveInputTokens == t.AboveInputTokens && t.Name == "" {
+				t.Name = e.Name
+			}
+		}
+		named[i] = t
+	}
+	return named
+}
+
+// sameRates compares base and tier rates, ignoring names and dates.
+func sameRates(a, b ModelPrice) bool {
+	if !samePrice(a.InputPer1K, b.InputPer1K) || !samePrice(a.OutputPer1K, b.OutputPer1K) {
+		return false
+	}
+	if len(a.Tiers) != len(b.Tiers) {
+		return false
+	}
+	ta, tb := sortedTiers(a.Tiers), sortedTiers(b.Tiers)
+	for i := range ta {
+		ta, tb := ta[i], tb[i]
+		if ta.AboveInputTokens != tb.AboveInputTokens ||
+			!samePrice(ta.InputPer1K, tb.InputPer1K) || !samePrice(ta.OutputPer1K, tb.OutputPer1K) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedTiers(tiers []PriceTier) []PriceTier {
+	sorted := append([]PriceTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].AboveInputTokens < sorted[j].AboveInputTokens
+	})
+	return sorted
+}
+
+func samePrice(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-12*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// Save writes the table as indented JSON, in the layout of prices.json.
+// The table is validated like Load does and nothing is written if it fails,
+// for example when Sync ran with a date before a model's current price.
+func (p *Prices) Save(path string) error {
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("invalid pricing table: %w", err)
+	}
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,161 @@
+package pricing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFeedServer serves the files in testdata, standing in for a price feed.
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchAndParseFeeds(t *testing.T) {
+	srv := newFeedServer(t)
+
+	tests := []struct {
+		name       string
+		src        string
+		wantModels int
+		model      string
+		wantInput  float64
+		wantOutput float64
+		wantTiers  int
+	}{
+		{"openrouter over http", srv.URL + "/openrouter_models.json", 3, "openai/gpt-4o", 0.002, 0.008, 0},
+		{"litellm over http", srv.URL + "/litellm_prices.json", 2, "claude-sonnet-4-20250514", 0.003, 0.015, 1},
+		{"litellm from file", filepath.Join("testdata", "litellm_prices.json"), 2, "gpt-4o", 0.0025, 0.01, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := FetchFeed(tt.src)
+			if err != nil {
+				t.Fatalf("FetchFeed failed: %v", err)
+			}
+			models, err := ParseFeed(data, "")
+			if err != nil {
+				t.Fatalf("ParseFeed failed: %v", err)
+			}
+			if len(models) != tt.wantModels {
+				t.Errorf("len(models) = %d, want %d", len(models), tt.wantModels)
+			}
+			mp, ok := models[tt.model]
+			if !ok {
+				t.Fatalf("model %q missing from feed", tt.model)
+			}
+			if mp.InputPer1K != tt.wantInput || mp.OutputPer1K != tt.wantOutput {
+				t.Errorf("%s = %v / %v, want %v / %v", tt.model, mp.InputPer1K, mp.OutputPer1K, tt.wantInput, tt.wantOutput)
+			}
+			if len(mp.Tiers) != tt.wantTiers {
+				t.Errorf("len(Tiers) = %d, want %d", len(mp.Tiers), tt.wantTiers)
+			}
+		})
+	}
+
+	if _, err := FetchFeed(srv.URL + "/missing.json"); err == nil {
+		t.Error("Expected error for missing feed")
+	}
+}
+
+func TestSync(t *testing.T) {
+	p := &Prices{
+		AsOf: "2026-01-01",
+		Models: map[string]ModelPrice{
+			"openai/gpt-4o":      {InputPer1K: 0.0025, OutputPer1K: 0.01, ServiceTiers: map[string]float64{"priority": 1.7}},
+			"openai/gpt-4o-mini": {InputPer1K: 0.00015, OutputPer1K: 0.0006},
+		},
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "openrouter_models.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := ParseFeed(data, FeedOpenRouter)
+	if err != nil {
+		t.Fatalf("ParseFeed failed: %v", err)
+	}
+
+	asOf := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	changes := p.Sync(feed, asOf, false)
+
+	if len(changes) != 1 || changes[0].Model != "openai/gpt-4o" {
+		t.Fatalf("changes = %v, want only openai/gpt-4o", changes)
+	}
+	if !strings.Contains(changes[0].String(), "$2.5 / $10 per 1M -> $2 / $8 per 1M") {
+		t.Errorf("diff line = %q", changes[0].String())
+	}
+	if p.AsOf != "2026-03-01" {
+		t.Errorf("AsOf = %q, want 2026-03-01", p.AsOf)
+	}
+	if _, ok := p.Models["mistralai/mistral-small"]; ok {
+		t.Error("Expected new models to be skipped without addNew")
+	}
+
+	mp := p.Models["openai/gpt-4o"]
+	if mp.EffectiveFrom != "2026-03-01" || len(mp.History) != 1 || mp.History[0].EffectiveTo != "2026-03-01" {
+		t.Errorf("unexpected effective dates: %+v", mp)
+	}
+	if mp.ServiceTiers["priority"] != 1.7 {
+		t.Error("Expected service tier multipliers to be kept")
+	}
+	if err := p.validate(); err != nil {
+		t.Errorf("synced table invalid: %v", err)
+	}
+
+	// Old ledger entries keep the old price.
+	before := p.ComputeCostAt("openai/gpt-4o", asOf.Add(-time.Hour), 1000, 0)
+	after := p.ComputeCostAt("openai/gpt-4o", asOf, 1000, 0)
+	if before.CostUSD.String() != "0.0025" || after.CostUSD.String() != "0.002" {
+		t.Errorf("cost before/after = %v / %v, want 0.0025 / 0.002", before.CostUSD, after.CostUSD)
+	}
+
+	// Syncing again the same day changes nothing; addNew brings in the rest.
+	changes = p.Sync(feed, asOf, true)
+	if len(changes) != 1 || changes[0].Model != "mistralai/mistral-small" || changes[0].Old != nil {
+		t.Errorf("changes = %v, want only added mistralai/mistral-small", changes)
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	p, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Models) != len(p.Models) || loaded.AsOf != p.AsOf {
+		t.Error("Saved table does not round-trip")
+	}
+}
+
+func TestSaveRejectsInvalidTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	p := &Prices{Models: map[string]ModelPrice{
+		"m": {InputPer1K: 1, OutputPer1K: 2, EffectiveFrom: "2026-03-01"},
+	}}
+
+	// A sync dated before the current price would expire it before it began.
+	p.Sync(map[string]ModelPrice{"m": {InputPer1K: 3, OutputPer1K: 4}},
+		time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), false)
+
+	if err := p.Save(path); err == nil {
+		t.Fatal("Save succeeded, want validation error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Save wrote %s despite the error", path)
+	}
+}
@@ -1,15 +1,18 @@
 package anthropic
 
 import (
+	"bytes"
 	"encoding/json"
 	"plarix-action/internal/ledger"
 )
 
 type response struct {
+	ID    string `json:"id"`
 	Model string `json:"model"`
 	Usage struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
+		InputTokens  int    `json:"input_tokens"`
+		OutputTokens int    `json:"output_tokens"`
+		ServiceTier  string `json:"service_tier"`
 	} `json:"usage"`
 }
 
@@ -23,6 +26,8 @@ func ParseResponse(body []byte, entry *ledger.Entry) {
 	}
 
 	entry.Model = resp.Model
+	entry.RequestID = resp.ID
+	entry.ServiceTier = resp.Usage.ServiceTier
 	entry.InputTokens = resp.Usage.InputTokens
 	entry.OutputTokens = resp.Usage.OutputTokens
 
@@ -30,3 +35,30 @@ func ParseResponse(body []byte, entry *ledger.Entry) {
 	// (Pricing calculation will determine if we actually know the price)
 	entry.CostKnown = true
 }
+
+// batchResultLine is one line of a Message Batches results file.
+type batchResultLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string          `json:"type"`
+		Message json.RawMessage `json:"message"`
+	} `json:"result"`
+}
+
+// ParseBatchResultLine parses one line of a Message Batches results file.
+// ok is false for blank lines and requests that were not billed.
+func ParseBatchResultLine(line []byte) (entry ledger.Entry, ok bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return entry, false
+	}
+
+	var l batchResultLine
+	if err := json.Unmarshal(line, &l); err != nil || l.Result.Type != "succeeded" {
+		return entry, false
+	}
+
+	entry.Batch = true
+	ParseResponse(l.Result.Message, &entry)
+	return entry, true
+}
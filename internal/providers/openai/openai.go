@@ -1,11 +1,13 @@
 // Package openai handles parsing OpenAI API responses.
 //
-// Purpose: Extract usage data from OpenAI Chat Completions and Responses API.
-// Public API: ParseResponse
+// Purpose: Extract usage data from OpenAI Chat Completions and Responses API,
+// and from Batch API output files.
+// Public API: ParseResponse, ParseBatchOutputLine
 // Usage: Call ParseResponse with response body to get ledger entry fields.
 package openai
 
 import (
+	"bytes"
 	"encoding/json"
 
 	"plarix-action/internal/ledger"
@@ -13,10 +15,11 @@ import (
 
 // Response represents an OpenAI API response with usage data.
 type Response struct {
-	ID     string `json:"id"`
-	Model  string `json:"model"`
-	Object string `json:"object"`
-	Usage  *Usage `json:"usage,omitempty"`
+	ID          string `json:"id"`
+	Model       string `json:"model"`
+	Object      string `json:"object"`
+	ServiceTier string `json:"service_tier,omitempty"`
+	Usage       *Usage `json:"usage,omitempty"`
 }
 
 // Usage holds token usage from OpenAI response.
@@ -41,6 +44,7 @@ func ParseResponse(body []byte, entry *ledger.Entry) {
 
 	entry.Model = resp.Model
 	entry.RequestID = resp.ID
+	entry.ServiceTier = resp.ServiceTier
 
 	if resp.Usage == nil {
 		entry.CostKnown = false
@@ -69,3 +73,35 @@ func ParseResponse(body []byte, entry *ledger.Entry) {
 	// Mark as knowing tokens but cost calculation is external
 	entry.CostKnown = true
 }
+
+// batchOutputLine is one line of a Batch API output file.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+}
+
+// ParseBatchOutputLine parses one line of a Batch API output file (JSONL, as
+// served by /v1/files/{id}/content) into an entry marked as a batch call. ok
+// is false for blank lines, failed requests and lines that are not batch
+// results, so ordinary file downloads yield no entries.
+func ParseBatchOutputLine(line []byte) (entry ledger.Entry, ok bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return entry, false
+	}
+
+	var l batchOutputLine
+	if err := json.Unmarshal(line, &l); err != nil || l.Response == nil || len(l.Response.Body) == 0 {
+		return entry, false
+	}
+	if l.Response.StatusCode < 200 || l.Response.StatusCode >= 300 {
+		return entry, false
+	}
+
+	entry.Batch = true
+	ParseResponse(l.Response.Body, &entry)
+	return entry, true
+}
@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"strings"
 	"testing"
 
 	"plarix-action/internal/ledger"
@@ -15,6 +16,7 @@ func TestParseResponse(t *testing.T) {
 		wantOutput    int
 		wantCostKnown bool
 		wantReason    string
+		wantTier      string
 	}{
 		{
 			name: "valid chat completion",
@@ -62,6 +64,20 @@ func TestParseResponse(t *testing.T) {
 			wantOutput:    100,
 			wantCostKnown: true,
 		},
+		{
+			name: "flex service tier",
+			body: `{
+				"id": "chatcmpl-789",
+				"model": "o3",
+				"service_tier": "flex",
+				"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+			}`,
+			wantModel:     "o3",
+			wantInput:     10,
+			wantOutput:    5,
+			wantCostKnown: true,
+			wantTier:      "flex",
+		},
 		{
 			name:          "invalid json",
 			body:          `{invalid}`,
@@ -90,6 +106,43 @@ func TestParseResponse(t *testing.T) {
 			if tt.wantReason != "" && entry.UnknownReason != tt.wantReason {
 				t.Errorf("UnknownReason = %q, want %q", entry.UnknownReason, tt.wantReason)
 			}
+			if entry.ServiceTier != tt.wantTier {
+				t.Errorf("ServiceTier = %q, want %q", entry.ServiceTier, tt.wantTier)
+			}
 		})
 	}
 }
+
+func TestParseBatchOutputLine(t *testing.T) {
+	body := `{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"request_id":"r1","body":{"id":"chatcmpl-1","model":"gpt-4o-mini","usage":{"prompt_tokens":20,"completion_tokens":10,"total_tokens":30}}},"error":null}
+{"id":"batch_req_2","custom_id":"b","response":{"status_code":400,"request_id":"r2","body":{"error":{"message":"bad"}}},"error":null}
+{"id":"batch_req_3","custom_id":"c","response":null,"error":{"code":"batch_expired","message":"expired"}}
+
+{"id":"batch_req_4","custom_id":"d","response":{"status_code":200,"request_id":"r4","body":{"id":"chatcmpl-4","model":"gpt-4o-mini","usage":{"prompt_tokens":5,"completion_tokens":5,"total_tokens":10}}},"error":null}
+`
+	var entries []ledger.Entry
+	for _, line := range strings.Split(body, "\n") {
+		if e, ok := ParseBatchOutputLine([]byte(line)); ok {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if !e.Batch {
+			t.Errorf("entry %s: Batch = false, want true", e.RequestID)
+		}
+		if !e.CostKnown {
+			t.Errorf("entry %s: CostKnown = false, want true", e.RequestID)
+		}
+	}
+	if entries[0].InputTokens != 20 || entries[1].RequestID != "chatcmpl-4" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	// Ordinary (non-batch) file content yields nothing.
+	if e, ok := ParseBatchOutputLine([]byte(`{"messages":[{"role":"user","content":"hi"}]}`)); ok {
+		t.Errorf("ParseBatchOutputLine() = %+v for a non-batch file, want none", e)
+	}
+}
@@ -3,18 +3,24 @@ package openrouter
 import (
 	"encoding/json"
 	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
 )
 
 type response struct {
-	Model string `json:"model"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"` // OpenRouter might send this
+	ID       string `json:"id"`       // Generation ID, e.g. "gen-..."
+	Provider string `json:"provider"` // Upstream provider that served the call
+	Model    string `json:"model"`
+	Usage    *struct {
+		PromptTokens     int        `json:"prompt_tokens"`
+		CompletionTokens int        `json:"completion_tokens"`
+		TotalTokens      int        `json:"total_tokens"` // OpenRouter might send this
+		Cost             *money.USD `json:"cost"`         // Credits charged (USD), when reported
 	} `json:"usage"`
 }
 
 // ParseResponse extracts usage from OpenRouter API response.
+// When OpenRouter reports the charged cost (usage.cost), it is stored as
+// ReportedCostUSD and takes precedence over the local pricing table.
 func ParseResponse(body []byte, entry *ledger.Entry) {
 	var resp response
 	if err := json.Unmarshal(body, &resp); err != nil {
@@ -26,19 +32,18 @@ func ParseResponse(body []byte, entry *ledger.Entry) {
 	// OpenRouter models are often prefixed like "openai/gpt-4"
 	// We might want to keep the full name or strip. Plarix usually wants full name.
 	entry.Model = resp.Model
-	entry.InputTokens = resp.Usage.PromptTokens
-	entry.OutputTokens = resp.Usage.CompletionTokens
+	entry.RequestID = resp.ID
+	entry.UpstreamProvider = resp.Provider
 
-	// If usage is zero, it might be missing
-	if entry.InputTokens == 0 && entry.OutputTokens == 0 && resp.Usage.TotalTokens == 0 {
-		// OpenRouter usage is sometimes missing or delayed?
-		// If it's zero, we can't compute cost.
-		// However, legitimate 0 token requests are rare.
-		// We will assume if fields are present, we use them.
+	if resp.Usage == nil {
+		entry.CostKnown = false
+		entry.UnknownReason = "no usage field in response"
+		return
 	}
 
-	entry.CostKnown = true
+	entry.InputTokens = resp.Usage.PromptTokens
+	entry.OutputTokens = resp.Usage.CompletionTokens
+	entry.ReportedCostUSD = resp.Usage.Cost
 
-	// Some OpenRouter reponses might not include usage if not requested?
-	// Standard OpenAI format usually includes it.
+	entry.CostKnown = true
 }
@@ -0,0 +1,105 @@
+package openrouter
+
+import (
+	"testing"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantModel     string
+		wantInput     int
+		wantOutput    int
+		wantCostKnown bool
+		wantReported  *money.USD
+		wantUpstream  string
+	}{
+		{
+			name: "reported cost",
+			body: `{
+				"id": "gen-123",
+				"provider": "OpenAI",
+				"model": "openai/gpt-4o",
+				"usage": {"prompt_tokens": 100, "completion_tokens": 50, "total_tokens": 150, "cost": 0.00075}
+			}`,
+			wantModel:     "openai/gpt-4o",
+			wantInput:     100,
+			wantOutput:    50,
+			wantCostKnown: true,
+			wantReported:  usdPtr(money.FromFloat(0.00075)),
+			wantUpstream:  "OpenAI",
+		},
+		{
+			name: "free model reports zero cost",
+			body: `{
+				"id": "gen-456",
+				"provider": "Meta",
+				"model": "meta-llama/llama-3.1-8b-instruct:free",
+				"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15, "cost": 0}
+			}`,
+			wantModel:     "meta-llama/llama-3.1-8b-instruct:free",
+			wantInput:     10,
+			wantOutput:    5,
+			wantCostKnown: true,
+			wantReported:  usdPtr(0),
+			wantUpstream:  "Meta",
+		},
+		{
+			name: "no reported cost",
+			body: `{
+				"id": "gen-789",
+				"model": "openai/gpt-4o-mini",
+				"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+			}`,
+			wantModel:     "openai/gpt-4o-mini",
+			wantInput:     10,
+			wantOutput:    5,
+			wantCostKnown: true,
+		},
+		{
+			name:          "missing usage",
+			body:          `{"id": "gen-000", "model": "openai/gpt-4o"}`,
+			wantModel:     "openai/gpt-4o",
+			wantCostKnown: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &ledger.Entry{}
+			ParseResponse([]byte(tt.body), entry)
+
+			if entry.Model != tt.wantModel {
+				t.Errorf("Model = %q, want %q", entry.Model, tt.wantModel)
+			}
+			if entry.InputTokens != tt.wantInput {
+				t.Errorf("InputTokens = %d, want %d", entry.InputTokens, tt.wantInput)
+			}
+			if entry.OutputTokens != tt.wantOutput {
+				t.Errorf("OutputTokens = %d, want %d", entry.OutputTokens, tt.wantOutput)
+			}
+			if entry.CostKnown != tt.wantCostKnown {
+				t.Errorf("CostKnown = %v, want %v", entry.CostKnown, tt.wantCostKnown)
+			}
+			if entry.UpstreamProvider != tt.wantUpstream {
+				t.Errorf("UpstreamProvider = %q, want %q", entry.UpstreamProvider, tt.wantUpstream)
+			}
+			switch {
+			case tt.wantReported == nil && entry.ReportedCostUSD != nil:
+				t.Errorf("ReportedCostUSD = %v, want nil", *entry.ReportedCostUSD)
+			case tt.wantReported != nil && entry.ReportedCostUSD == nil:
+				t.Errorf("ReportedCostUSD = nil, want %v", *tt.wantReported)
+			case tt.wantReported != nil && *entry.ReportedCostUSD != *tt.wantReported:
+				t.Errorf("ReportedCostUSD = %v, want %v", *entry.ReportedCostUSD, *tt.wantReported)
+			}
+		})
+	}
+}
+
+func usdPtr(a money.USD) *money.USD {
+	return &a
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"plarix-action/internal/ledger"
+)
+
+// APIPrefix is the path prefix of the query API served from Config.Store:
+//
+//	GET /_plarix/entries  matching ledger entries as JSON lines
+//	GET /_plarix/summary  the summary of the matching entries
+//
+// Both accept since and until (RFC 3339 or YYYY-MM-DD), provider, model
+// and tag=key=value (repeatable) query parameters; summary also accepts
+// bucket (minute, hour, day or a duration) for a time series.
+//
+// The API is unauthenticated for loopback clients only. When
+// Config.APIToken is set, every client must send it as
+// "Authorization: Bearer <token>" instead.
+const APIPrefix = "/_plarix/"
+
+// serveAPI handles requests under APIPrefix.
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if s.config.Store == nil {
+		http.Error(w, "query API is not enabled", http.StatusNotFound)
+		return
+	}
+	if status, msg := s.authorizeAPI(r); status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, APIPrefix) {
+	case "entries":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		err := s.config.Store.Query(q, func(e ledger.Entry) error {
+			return enc.Encode(e)
+		})
+		if err != nil {
+			// Part of the response may be sent; end it with the error.
+			enc.Encode(map[string]string{"error": err.Error()})
+		}
+		bw.Flush()
+	case "summary":
+		width, err := ledger.ParseBucketWidth(r.URL.Query().Get("bucket"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		agg := ledger.NewAggregator()
+		agg.SetBucketWidth(width)
+		err = s.config.Store.Query(q, func(e ledger.Entry) error {
+			agg.Add(e)
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(agg.Summary())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorizeAPI checks a query API request against Config.APIToken. It returns
+// the error status and message for a rejected request, or 0.
+func (s *Server) authorizeAPI(r *http.Request) (int, string) {
+	if s.config.APIToken == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			return http.StatusForbidden, "query API is only served to loopback clients without an API token"
+		}
+		return 0, ""
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.APIToken)) != 1 {
+		return http.StatusUnauthorized, "missing or invalid API token"
+	}
+	return 0, ""
+}
+
+// parseQuery builds a ledger query from API query parameters.
+func parseQuery(v url.Values) (ledger.Query, error) {
+	var q ledger.Query
+	var err error
+	if q.Since, err = ledger.ParseTime(v.Get("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = ledger.ParseTime(v.Get("until")); err != nil {
+		return q, err
+	}
+	q.Provider = v.Get("provider")
+	q.Model = v.Get("model")
+	if tags := v["tag"]; len(tags) > 0 {
+		if q.Tags, err = ledger.ParseTags(strings.Join(tags, ",")); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
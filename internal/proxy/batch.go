@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/providers/anthropic"
+	"plarix-action/internal/providers/openai"
+)
+
+// Batch APIs are billed when results are produced, not when a batch is
+// submitted. The proxy therefore ignores the batch management endpoints
+// (create, poll, cancel) and records usage from the result downloads:
+//
+//	OpenAI:    GET /v1/files/{id}/content            (output file, JSONL)
+//	Anthropic: GET /v1/messages/batches/{id}/results (results, JSONL)
+
+// maxBatchLine bounds the bytes buffered for one results line. Longer lines
+// are passed through to the client but not parsed.
+const maxBatchLine = 16 << 20
+
+// maxBatchSeen bounds the request IDs remembered from results downloads,
+// enough for a few full batches; the oldest are forgotten first.
+var maxBatchSeen = 200000 // replaced in tests
+
+// isBatchManagement reports whether the endpoint creates, lists, polls or
+// cancels a batch. Its responses carry no usage and are not recorded.
+func isBatchManagement(provider, endpoint string) bool {
+	switch provider {
+	case "openai":
+		return endpoint == "/v1/batches" || strings.HasPrefix(endpoint, "/v1/batches/")
+	case "anthropic":
+		return (endpoint == "/v1/messages/batches" || strings.HasPrefix(endpoint, "/v1/messages/batches/")) &&
+			!isBatchResults(provider, endpoint)
+	}
+	return false
+}
+
+// isBatchResults reports whether the endpoint downloads batch results.
+// For OpenAI this is any file download; files that are not batch output
+// yield no entries.
+func isBatchResults(provider, endpoint string) bool {
+	parts := strings.Split(strings.Trim(endpoint, "/"), "/")
+	switch provider {
+	case "openai":
+		// v1/files/{id}/content
+		return len(parts) == 4 && parts[0] == "v1" && parts[1] == "files" && parts[3] == "content"
+	case "anthropic":
+		// v1/messages/batches/{id}/results
+		return len(parts) == 5 && parts[0] == "v1" && parts[1] == "messages" &&
+			parts[2] == "batches" && parts[4] == "results"
+	}
+	return false
+}
+
+// handleBatchResults records one entry per billed request in a batch results
+// download. Lines are parsed as the client reads them, so the download is
+// not held back or buffered whole. Entries carry the download's tags but no
+// timing, which would describe the download rather than the batched calls.
+func (s *Server) handleBatchResults(call *callInfo, provider, endpoint string, resp *http.Response) error {
+	var parse func([]byte) (ledger.Entry, bool)
+	switch provider {
+	case "openai":
+		parse = openai.ParseBatchOutputLine
+	case "anthropic":
+		parse = anthropic.ParseBatchResultLine
+	default:
+		return nil
+	}
+
+	resp.Body = &batchInterceptor{
+		originalBody: resp.Body,
+		parse:        parse,
+		onEntry: func(e ledger.Entry) {
+			if !s.firstBatchResult(e.RequestID) {
+				return
+			}
+			e.Provider = provider
+			e.Endpoint = endpoint
+			s.emit(call, e, time.Time{}, time.Time{})
+		},
+	}
+	return nil
+}
+
+// firstBatchResult reports whether the request has not been recorded from a
+// results download yet, so downloading the same results twice does not
+// count their cost twice. Only the last maxBatchSeen request IDs are
+// remembered. Entries without a request ID are always recorded.
+func (s *Server) firstBatchResult(requestID string) bool {
+	if requestID == "" {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batchSeen[requestID] {
+		return false
+	}
+	if s.batchSeen == nil {
+		s.batchSeen = make(map[string]bool)
+	}
+	if len(s.batchOrder) < maxBatchSeen {
+		s.batchOrder = append(s.batchOrder, requestID)
+	} else {
+		delete(s.batchSeen, s.batchOrder[s.batchNext])
+		s.batchOrder[s.batchNext] = requestID
+		s.batchNext = (s.batchNext + 1) % len(s.batchOrder)
+	}
+	s.batchSeen[requestID] = true
+	return true
+}
+
+// batchInterceptor passes a JSONL results download through to the client
+// and parses each complete line as it goes by.
+type batchInterceptor struct {
+	originalBody io.ReadCloser
+	parse        func([]byte) (ledger.Entry, bool)
+	onEntry      func(ledger.Entry)
+
+	line     bytes.Buffer
+	overflow bool // the current line exceeded maxBatchLine
+}
+
+func (b *batchInterceptor) Read(p []byte) (n int, err error) {
+	n, err = b.originalBody.Read(p)
+	if n > 0 {
+		b.scan(p[:n])
+	}
+	if err == io.EOF {
+		b.flush()
+	}
+	return n, err
+}
+
+func (b *batchInterceptor) Close() error {
+	return b.originalBody.Close()
+}
+
+func (b *batchInterceptor) scan(chunk []byte) {
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			b.buffer(chunk)
+			return
+		}
+		b.buffer(chunk[:i])
+		b.flush()
+		chunk = chunk[i+1:]
+	}
+}
+
+func (b *batchInterceptor) buffer(part []byte) {
+	if b.overflow || b.line.Len()+len(part) > maxBatchLine {
+		b.overflow = true
+		b.line.Reset()
+		return
+	}
+	b.line.Write(part)
+}
+
+// flush parses the buffered line, if complete, and starts the next one.
+func (b *batchInterceptor) flush() {
+	if !b.overflow && b.line.Len() > 0 {
+		if e, ok := b.parse(b.line.Bytes()); ok {
+			b.onEntry(e)
+		}
+	}
+	b.line.Reset()
+	b.overflow = false
+}
@@ -1,7 +1,7 @@
 // Package proxy implements the HTTP forward proxy for LLM API interception.
 //
 // Purpose: Route LLM API calls through local proxy to extract usage data.
-// Public API: Server, Config, Handler
+// Public API: Server, Config, Handler, APIPrefix
 // Usage: Create Server with Config, call Start() to begin listening.
 package proxy
 
@@ -30,6 +30,63 @@ type Config struct {
 	Providers            []string           // e.g., ["openai", "anthropic", "openrouter"]
 	OnEntry              func(ledger.Entry) // Callback for each recorded entry
 	StreamUsageInjection bool               // Opt-in for OpenAI stream usage injection
+	Tags                 map[string]string  // Tags recorded on every entry
+	Store                ledger.Store       // Optional; serves the query API (see APIPrefix)
+	APIToken             string             // Bearer token for the query API; empty allows loopback clients only
+}
+
+// TagsHeader is the request header clients use to tag a call, e.g.
+// "X-Plarix-Tags: team=search,env=prod". It overrides Config.Tags per key
+// and is not forwarded upstream.
+const TagsHeader = "X-Plarix-Tags"
+
+// callInfo carries per-request data recorded on the call's entries.
+type callInfo struct {
+	start time.Time
+	tags  map[string]string
+}
+
+// newCallInfo starts timing a request and collects its tags, removing the
+// tags header so it does not reach the provider.
+func (s *Server) newCallInfo(r *http.Request) *callInfo {
+	call := &callInfo{start: time.Now()}
+	header := r.Header.Get(TagsHeader)
+	r.Header.Del(TagsHeader)
+
+	if len(s.config.Tags) == 0 && header == "" {
+		return call
+	}
+	call.tags = make(map[string]string, len(s.config.Tags))
+	for k, v := range s.config.Tags {
+		call.tags[k] = v
+	}
+	// Malformed pairs are ignored; the call itself must not fail.
+	tags, _ := ledger.ParseTags(header)
+	for k, v := range tags {
+		call.tags[k] = v
+	}
+	return call
+}
+
+// emit records an entry for the call. ttfb and done are the times the
+// response headers and the end of the response were seen; zero values leave
+// the timing unset (e.g. for batch results, which are not timed per call).
+func (s *Server) emit(call *callInfo, e ledger.Entry, ttfb, done time.Time) {
+	if len(call.tags) > 0 {
+		e.Tags = make(map[string]string, len(call.tags))
+		for k, v := range call.tags {
+			e.Tags[k] = v
+		}
+	}
+	if !ttfb.IsZero() {
+		e.TTFBMS = ttfb.Sub(call.start).Milliseconds()
+	}
+	if !done.IsZero() {
+		e.DurationMS = done.Sub(call.start).Milliseconds()
+	}
+	if s.config.OnEntry != nil {
+		s.config.OnEntry(e)
+	}
 }
 
 // Server is the HTTP forward proxy server.
@@ -39,6 +96,9 @@ type Server struct {
 	httpServer *http.Server
 	mu         sync.Mutex
 	started    bool
+	batchSeen  map[string]bool // request IDs recorded from batch results
+	batchOrder []string        // batchSeen's IDs, a ring of maxBatchSeen
+	batchNext  int             // next slot of batchOrder to reuse
 }
 
 // providerTargets maps provider names to their API base URLs.
@@ -126,6 +186,11 @@ func (s *Server) Port() int {
 
 // ServeHTTP handles incoming proxy requests.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, APIPrefix) {
+		s.serveAPI(w, r)
+		return
+	}
+
 	// Extract provider from path prefix: /openai/v1/... -> openai
 	pathParts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
 	if len(pathParts) < 1 {
@@ -139,6 +204,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("unknown provider: %s", provider), http.StatusBadRequest)
 		return
 	}
+	call := s.newCallInfo(r)
 
 	// Reconstruct target path
 	targetPath := "/"
@@ -170,7 +236,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			req.Host = targetURL.Host
 		},
 		ModifyResponse: func(resp *http.Response) error {
-			return s.handleResponse(provider, targetPath, resp)
+			return s.handleResponse(call, provider, targetPath, resp)
 		},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
 			http.Error(w, fmt.Sprintf("proxy error: %v", err), http.StatusBadGateway)
@@ -222,12 +288,22 @@ func (s *Server) injectStreamOptions(r *http.Request) {
 }
 
 // handleResponse processes the API response to extract usage data.
-func (s *Server) handleResponse(provider, endpoint string, resp *http.Response) error {
+func (s *Server) handleResponse(call *callInfo, provider, endpoint string, resp *http.Response) error {
+	headersAt := time.Now()
+
 	// Only process successful responses
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil
 	}
 
+	if isBatchManagement(provider, endpoint) {
+		return nil
+	}
+	if isBatchResults(provider, endpoint) {
+		// Results files are JSONL served with a generic content type.
+		return s.handleBatchResults(call, provider, endpoint, resp)
+	}
+
 	contentType := resp.Header.Get("Content-Type")
 
 	// Detect streaming responses (SSE)
@@ -236,9 +312,7 @@ func (s *Server) handleResponse(provider, endpoint string, resp *http.Response)
 	if isStreaming {
 		// Wrap body to intercept usage
 		interceptor := newStreamInterceptor(resp.Body, provider, endpoint, func(e ledger.Entry) {
-			if s.config.OnEntry != nil {
-				s.config.OnEntry(e)
-			}
+			s.emit(call, e, headersAt, time.Now())
 		})
 		resp.Body = interceptor
 		return nil
@@ -255,6 +329,7 @@ func (s *Server) handleResponse(provider, endpoint string, resp *http.Response)
 		return nil // Don't fail the request if we can't read
 	}
 	resp.Body.Close()
+	doneAt := time.Now()
 
 	// Create new reader for the client
 	resp.Body = io.NopCloser(strings.NewReader(string(body)))
@@ -262,9 +337,7 @@ func (s *Server) handleResponse(provider, endpoint string, resp *http.Response)
 
 	// Parse usage based on provider
 	entry := s.parseUsage(provider, endpoint, body)
-	if s.config.OnEntry != nil {
-		s.config.OnEntry(entry)
-	}
+	s.emit(call, entry, headersAt, doneAt)
 
 	return nil
 }
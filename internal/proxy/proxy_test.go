@@ -6,10 +6,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
 )
 
 // TestProxyOpenAI tests the proxy with a mock OpenAI server.
@@ -109,3 +112,340 @@ func TestProxyOpenAI(t *testing.T) {
 		t.Errorf("CostKnown = false, want true")
 	}
 }
+
+// TestProxyAnthropicBatch tests that batch management calls are not recorded
+// and that batch results produce one batch entry per succeeded request.
+func TestProxyAnthropicBatch(t *testing.T) {
+	mockAnthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/messages/batches":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"msgbatch_1","type":"message_batch","processing_status":"in_progress"}`))
+		case "/v1/messages/batches/msgbatch_1/results":
+			w.Header().Set("Content-Type", "application/binary")
+			w.Write([]byte(`{"custom_id":"a","result":{"type":"succeeded","message":{"id":"msg_a","model":"claude-3-5-haiku-20241022","usage":{"input_tokens":10,"output_tokens":20,"service_tier":"batch"}}}}
+{"custom_id":"b","result":{"type":"errored","error":{"type":"invalid_request"}}}
+{"custom_id":"c","result":{"type":"succeeded","message":{"id":"msg_c","model":"claude-3-5-haiku-20241022","usage":{"input_tokens":5,"output_tokens":5,"service_tier":"batch"}}}}
+`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockAnthropic.Close()
+
+	originalTarget := providerTargets["anthropic"]
+	providerTargets["anthropic"] = mockAnthropic.URL
+	defer func() { providerTargets["anthropic"] = originalTarget }()
+
+	entryCh := make(chan ledger.Entry, 10)
+	server := NewServer(Config{
+		Providers: []string{"anthropic"},
+		OnEntry: func(e ledger.Entry) {
+			entryCh <- e
+		},
+	})
+	port, err := server.Start()
+	if err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer server.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	base := fmt.Sprintf("http://127.0.0.1:%d/anthropic", port)
+
+	resp, err := client.Post(base+"/v1/messages/batches", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// Downloading the results again must not record them twice.
+	for i := 0; i < 2; i++ {
+		resp, err = client.Get(base + "/v1/messages/batches/msgbatch_1/results")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if len(body) == 0 {
+			t.Error("Expected results body to be forwarded")
+		}
+	}
+
+	var entries []ledger.Entry
+	timeout := time.After(time.Second)
+	for len(entries) < 2 {
+		select {
+		case e := <-entryCh:
+			entries = append(entries, e)
+		case <-timeout:
+			t.Fatalf("Timeout waiting for entries, got %d", len(entries))
+		}
+	}
+	select {
+	case e := <-entryCh:
+		t.Fatalf("Unexpected extra entry: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for _, e := range entries {
+		if !e.Batch || e.Provider != "anthropic" || e.ServiceTier != "batch" {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+		if e.Endpoint != "/v1/messages/batches/msgbatch_1/results" {
+			t.Errorf("Endpoint = %q", e.Endpoint)
+		}
+	}
+	if entries[0].InputTokens != 10 || entries[1].RequestID != "msg_c" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+// TestProxyOpenAIBatchStreams checks that batch output is recorded line by
+// line while the download is still in progress.
+func TestProxyOpenAIBatchStreams(t *testing.T) {
+	release := make(chan struct{})
+	mockOpenAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprintln(w, `{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"body":{"id":"chatcmpl-1","model":"gpt-4o-mini","usage":{"prompt_tokens":10,"completion_tokens":5}}}}`)
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, `{"id":"batch_req_2","custom_id":"b","response":{"status_code":200,"body":{"id":"chatcmpl-2","model":"gpt-4o-mini","usage":{"prompt_tokens":1,"completion_tokens":1}}}}`)
+	}))
+	defer mockOpenAI.Close()
+	defer close(release)
+
+	originalTarget := providerTargets["openai"]
+	providerTargets["openai"] = mockOpenAI.URL
+	defer func() { providerTargets["openai"] = originalTarget }()
+
+	entryCh := make(chan ledger.Entry, 10)
+	server := NewServer(Config{
+		Providers: []string{"openai"},
+		OnEntry: func(e ledger.Entry) {
+			entryCh <- e
+		},
+	})
+	port, err := server.Start()
+	if err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer server.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/openai/v1/files/file-1/content", port))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan []byte)
+	go func() {
+		body, _ := io.ReadAll(resp.Body)
+		done <- body
+	}()
+
+	select {
+	case e := <-entryCh:
+		if e.RequestID != "chatcmpl-1" || !e.Batch || e.Endpoint != "/v1/files/file-1/content" {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first line was not recorded before the download finished")
+	}
+
+	// The last line has no trailing newline and is recorded at EOF.
+	release <- struct{}{}
+	if body := <-done; !strings.HasSuffix(string(body), "}}}}") {
+		t.Errorf("body not forwarded intact: %q", body)
+	}
+	select {
+	case e := <-entryCh:
+		if e.RequestID != "chatcmpl-2" || e.InputTokens != 1 {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the last entry")
+	}
+}
+
+func TestFirstBatchResultForgetsOldest(t *testing.T) {
+	defer func(n int) { maxBatchSeen = n }(maxBatchSeen)
+	maxBatchSeen = 3
+
+	s := NewServer(Config{})
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"a", true}, {"b", true}, {"a", false}, {"c", true},
+		{"d", true}, // forgets a
+		{"b", false}, {"a", true}, {"b", true}, {"", true}, {"", true},
+	}
+	for i, tt := range tests {
+		if got := s.firstBatchResult(tt.id); got != tt.want {
+			t.Errorf("%d: firstBatchResult(%q) = %v, want %v", i, tt.id, got, tt.want)
+		}
+	}
+	if len(s.batchSeen) != maxBatchSeen {
+		t.Errorf("remembered %d request IDs, want %d", len(s.batchSeen), maxBatchSeen)
+	}
+}
+
+// TestProxyTagsAndTiming checks that tags from config and the request header
+// are recorded, the header is not forwarded, and the call is timed.
+func TestProxyTagsAndTiming(t *testing.T) {
+	forwardedTags := make(chan string, 1)
+	mockOpenAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedTags <- r.Header.Get(TagsHeader)
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"gpt-4o","usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+	}))
+	defer mockOpenAI.Close()
+
+	originalTarget := providerTargets["openai"]
+	providerTargets["openai"] = mockOpenAI.URL
+	defer func() { providerTargets["openai"] = originalTarget }()
+
+	entryCh := make(chan ledger.Entry, 1)
+	server := NewServer(Config{
+		Providers: []string{"openai"},
+		Tags:      map[string]string{"env": "ci", "team": "default"},
+		OnEntry:   func(e ledger.Entry) { entryCh <- e },
+	})
+	port, err := server.Start()
+	if err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer server.Stop()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:%d/openai/v1/chat/completions", port), nil)
+	req.Header.Set(TagsHeader, "team=search, service=api")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if got := <-forwardedTags; got != "" {
+		t.Errorf("%s forwarded upstream as %q", TagsHeader, got)
+	}
+
+	select {
+	case e := <-entryCh:
+		want := map[string]string{"env": "ci", "team": "search", "service": "api"}
+		if fmt.Sprint(e.Tags) != fmt.Sprint(want) {
+			t.Errorf("Tags = %v, want %v", e.Tags, want)
+		}
+		if e.DurationMS < 20 {
+			t.Errorf("DurationMS = %d, want >= 20", e.DurationMS)
+		}
+		if e.TTFBMS < 20 || e.TTFBMS > e.DurationMS {
+			t.Errorf("TTFBMS = %d, want between 20 and DurationMS (%d)", e.TTFBMS, e.DurationMS)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for entry")
+	}
+}
+
+func TestQueryAPI(t *testing.T) {
+	db, err := ledger.OpenDB(filepath.Join(t.TempDir(), "store.jsonl"), ledger.Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	for _, e := range []ledger.Entry{
+		{Timestamp: "2026-10-17T12:00:00Z", Provider: "openai", Model: "gpt-4o", CostUSD: money.FromFloat(1), CostKnown: true, Tags: map[string]string{"service": "search"}},
+		{Timestamp: "2026-10-18T12:00:00Z", Provider: "openai", Model: "gpt-4o", CostUSD: money.FromFloat(2), CostKnown: true, Tags: map[string]string{"service": "search"}},
+		{Timestamp: "2026-10-18T13:00:00Z", Provider: "openai", Model: "gpt-4o", CostUSD: money.FromFloat(4), CostKnown: true, Tags: map[string]string{"service": "ads"}},
+	} {
+		db.Write(e)
+	}
+	server := NewServer(Config{Store: db, APIToken: "s3cret"})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/_plarix/summary?since=2026-10-18&until=2026-10-19&model=gpt-4o&tag=service=search&bucket=hour")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("summary status = %d: %s", rec.Code, rec.Body)
+	}
+	var s ledger.Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if s.TotalCalls != 1 || s.TotalKnownCostUSD != money.FromFloat(2) {
+		t.Errorf("summary = %d calls, $%s; want 1 call, $2", s.TotalCalls, s.TotalKnownCostUSD)
+	}
+	if len(s.Buckets) != 1 || s.Buckets[0].Start.Hour() != 12 {
+		t.Errorf("summary buckets = %+v, want one at 12:00", s.Buckets)
+	}
+
+	rec = get("/_plarix/entries?provider=openai&since=2026-10-18")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if rec.Code != http.StatusOK || len(lines) != 2 {
+		t.Errorf("entries status = %d, %d lines, want 200 and 2 lines:\n%s", rec.Code, len(lines), rec.Body)
+	}
+
+	if rec := get("/_plarix/entries?since=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since: status = %d, want 400", rec.Code)
+	}
+	if rec := get("/_plarix/summary?bucket=weekly"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad bucket: status = %d, want 400", rec.Code)
+	}
+	if rec := get("/_plarix/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown endpoint: status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(Config{}).ServeHTTP(rec, httptest.NewRequest("GET", "/_plarix/summary", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without a store: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestQueryAPIAuth(t *testing.T) {
+	db, err := ledger.OpenDB(filepath.Join(t.TempDir(), "store.jsonl"), ledger.Options{})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name       string
+		token      string // Config.APIToken
+		remoteAddr string
+		auth       string // Authorization header
+		want       int
+	}{
+		{"loopback without token", "", "127.0.0.1:5000", "", http.StatusOK},
+		{"loopback ipv6 without token", "", "[::1]:5000", "", http.StatusOK},
+		{"remote without token", "", "192.0.2.1:5000", "", http.StatusForbidden},
+		{"remote with token", "s3cret", "192.0.2.1:5000", "Bearer s3cret", http.StatusOK},
+		{"remote with wrong token", "s3cret", "192.0.2.1:5000", "Bearer guess", http.StatusUnauthorized},
+		{"bare token", "s3cret", "192.0.2.1:5000", "s3cret", http.StatusUnauthorized},
+		{"loopback without the token", "s3cret", "127.0.0.1:5000", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/_plarix/summary", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			NewServer(Config{Store: db, APIToken: tt.token}).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
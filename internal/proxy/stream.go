@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
 )
 
 // usageStreamInterceptor wraps an io.ReadCloser (the upstream response body)
@@ -105,8 +106,9 @@ func (s *usageStreamInterceptor) processLine(line []byte) {
 	}
 
 	// Check provider specific usage
-	if s.provider == "openai" {
+	if s.provider == "openai" || s.provider == "openrouter" {
 		// OpenAI stream_options usage comes in a separate chunk, usually the last one.
+		// OpenRouter streams use the same chunk format, plus usage.cost and provider.
 		// { "usage": { ... } }
 		if usage, ok := payload["usage"].(map[string]interface{}); ok {
 			s.extractOpenAIUsage(usage)
@@ -118,6 +120,17 @@ func (s *usageStreamInterceptor) processLine(line []byte) {
 		if model, ok := payload["model"].(string); ok && s.entry.Model == "" {
 			s.entry.Model = model
 		}
+		if tier, ok := payload["service_tier"].(string); ok && tier != "" {
+			s.entry.ServiceTier = tier
+		}
+		if s.provider == "openrouter" {
+			if id, ok := payload["id"].(string); ok && s.entry.RequestID == "" {
+				s.entry.RequestID = id
+			}
+			if upstream, ok := payload["provider"].(string); ok && upstream != "" {
+				s.entry.UpstreamProvider = upstream
+			}
+		}
 	} else if s.provider == "anthropic" {
 		// Anthropic SSE:
 		// event: message_start -> data: { message: { usage: {...} } }
@@ -153,6 +166,10 @@ func (s *usageStreamInterceptor) extractOpenAIUsage(usage map[string]interface{}
 	if ct, ok := usage["completion_tokens"].(float64); ok {
 		s.entry.OutputTokens = int(ct)
 	}
+	if cost, ok := usage["cost"].(float64); ok {
+		reported := money.FromFloat(cost)
+		s.entry.ReportedCostUSD = &reported
+	}
 	// If we found usage, we mark it potentially known (depends on pricing)
 	// But we definitely "found usage".
 	s.entry.CostKnown = true
@@ -166,6 +183,9 @@ func (s *usageStreamInterceptor) accumulateAnthropicUsage(usage map[string]inter
 	if ot, ok := usage["output_tokens"].(float64); ok {
 		s.entry.OutputTokens += int(ot)
 	}
+	if tier, ok := usage["service_tier"].(string); ok && tier != "" {
+		s.entry.ServiceTier = tier
+	}
 	// Mark as found
 	s.entry.CostKnown = true
 	s.entry.UnknownReason = ""
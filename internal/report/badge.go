@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Badge colors.
+const (
+	badgeGreen = "#4c1"
+	badgeRed   = "#e05d44"
+	badgeGrey  = "#9f9f9f"
+)
+
+// BadgeLabel is the left-hand text of the cost badge.
+const BadgeLabel = "LLM cost"
+
+// Badge writes a flat SVG badge with the run's total known cost, such as
+// "LLM cost | $0.42/run". It is green, red when a budget rule failed, or
+// grey when no calls were observed.
+func Badge(w io.Writer, d Data) error {
+	message := trimCents(d.Cost(d.Summary.TotalKnownCostUSD)) + "/run"
+	color := badgeGreen
+	if d.Summary.TotalCalls == 0 {
+		color = badgeGrey
+	}
+	for _, r := range d.Budgets {
+		if !r.Passed {
+			color = badgeRed
+		}
+	}
+	_, err := io.WriteString(w, badgeSVG(BadgeLabel, message, color))
+	return err
+}
+
+// trimCents drops trailing zeros beyond two decimals: "$0.4200" becomes
+// "$0.42", while "$0.000012" keeps the digits that make it visible.
+func trimCents(cost string) string {
+	dot := strings.LastIndex(cost, ".")
+	if dot < 0 {
+		return cost
+	}
+	end := len(cost)
+	for end > dot+3 && cost[end-1] == '0' {
+		end--
+	}
+	return cost[:end]
+}
+
+// badgeSVG lays out a badge in the shields.io flat style.
+func badgeSVG(label, message, color string) string {
+	lw, mw := textWidth(label)+10, textWidth(message)+10
+	title := html.EscapeString(label + ": " + message)
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s">`+"\n", lw+mw, title)
+	fmt.Fprintf(&b, "<title>%s</title>\n", title)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` + "\n")
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+"\n", lw+mw)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`+"\n",
+		lw, lw, mw, color, lw+mw)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` + "\n")
+	for _, t := range []struct {
+		x    float64
+		text string
+	}{{float64(lw) / 2, label}, {float64(lw) + float64(mw)/2, message}} {
+		fmt.Fprintf(&b, `<text x="%g" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%g" y="14">%s</text>`+"\n", t.x, t.text, t.x, t.text)
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.String()
+}
+
+// textWidth approximates the width in pixels of s in 11px Verdana.
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("il.,:;|!'/ ", r):
+			w += 4
+		case strings.ContainsRune("mwMW%", r):
+			w += 10
+		case r >= 'A' && r <= 'Z':
+			w += 8
+		default:
+			w += 7
+		}
+	}
+	return w
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"plarix-action/internal/budget"
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+func TestBadge(t *testing.T) {
+	ran := ledger.Summary{TotalCalls: 3, TotalKnownCostUSD: money.FromFloat(0.42)}
+	eur := ran
+	eur.SetCurrency(ledger.Currency{Code: "EUR", PerUSD: 0.5})
+
+	tests := []struct {
+		name    string
+		d       Data
+		message string
+		color   string
+	}{
+		{"ok", Data{Summary: ran}, "$0.42/run", badgeGreen},
+		{"budget failed", Data{Summary: ran, Budgets: []budget.Result{{Passed: true}, {Passed: false}}}, "$0.42/run", badgeRed},
+		{"no calls", Data{}, "$0.00/run", badgeGrey},
+		{"tiny", Data{Summary: ledger.Summary{TotalCalls: 1, TotalKnownCostUSD: money.FromFloat(0.000012)}}, "$0.000012/run", badgeGreen},
+		{"currency", Data{Summary: eur}, "€0.21/run", badgeGreen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := Badge(&b, tt.d); err != nil {
+				t.Fatal(err)
+			}
+			svg := b.String()
+			if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+				t.Fatalf("badge is not valid XML: %v\n%s", err, svg)
+			}
+			if want := "<title>LLM cost: " + tt.message + "</title>"; !strings.Contains(svg, want) {
+				t.Errorf("badge lacks %q:\n%s", want, svg)
+			}
+			if !strings.Contains(svg, `fill="`+tt.color+`"`) {
+				t.Errorf("badge is not %s:\n%s", tt.color, svg)
+			}
+		})
+	}
+}
+
+func TestTrimCents(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"$0.4200", "$0.42"},
+		{"$12.0000", "$12.00"},
+		{"$0.000012", "$0.000012"},
+		{"$0.1230", "$0.123"},
+		{"CHF 2.5000", "CHF 2.50"},
+		{"$5", "$5"},
+	}
+	for _, tt := range tests {
+		if got := trimCents(tt.in); got != tt.want {
+			t.Errorf("trimCents(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"sort"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+// Delta is the change in cost from a baseline run, such as the summary of
+// the base branch, to the current one.
+type Delta struct {
+	Baseline ledger.Summary
+	CostUSD  money.USD    // change in total known cost
+	Change   float64      // CostUSD relative to the baseline cost; 0 if that was 0
+	Calls    int          // change in calls
+	Models   []ModelDelta // models whose cost or calls changed, largest change first
+}
+
+// ModelDelta is the change in one model's known cost.
+type ModelDelta struct {
+	Name          string
+	Before, After money.USD
+	Change        money.USD
+	Calls         int // change in calls
+}
+
+// Compare returns the change from baseline to current.
+func Compare(current, baseline ledger.Summary) *Delta {
+	d := &Delta{
+		Baseline: baseline,
+		CostUSD:  current.TotalKnownCostUSD - baseline.TotalKnownCostUSD,
+		Calls:    current.TotalCalls - baseline.TotalCalls,
+	}
+	if baseline.TotalKnownCostUSD > 0 {
+		d.Change = float64(d.CostUSD) / float64(baseline.TotalKnownCostUSD)
+	}
+
+	names := make(map[string]bool)
+	for name := range current.ModelBreakdown {
+		names[name] = true
+	}
+	for name := range baseline.ModelBreakdown {
+		names[name] = true
+	}
+	for name := range names {
+		before, after := baseline.ModelBreakdown[name], current.ModelBreakdown[name]
+		m := ModelDelta{
+			Name:   name,
+			Before: before.KnownCostUSD,
+			After:  after.KnownCostUSD,
+			Change: after.KnownCostUSD - before.KnownCostUSD,
+			Calls:  after.Calls - before.Calls,
+		}
+		if m.Change != 0 || m.Calls != 0 {
+			d.Models = append(d.Models, m)
+		}
+	}
+	sort.Slice(d.Models, func(i, j int) bool {
+		a, b := d.Models[i].Change.Abs(), d.Models[j].Change.Abs()
+		if a != b {
+			return a > b
+		}
+		return d.Models[i].Name < d.Models[j].Name
+	})
+	return d
+}
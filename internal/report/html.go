@@ -0,0 +1,136 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+//go:embed html.tmpl
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"ms": func(p *ledger.Percentiles) string {
+		if p == nil {
+			return ""
+		}
+		return fmt.Sprintf("%g / %g ms", p.P50, p.P99)
+	},
+	"tags": func(m map[string]string) string {
+		pairs := make([]string, 0, len(m))
+		for k, v := range m {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ", ")
+	},
+}).Parse(htmlSource))
+
+// htmlPage is the data of html.tmpl.
+type htmlPage struct {
+	S         ledger.Summary
+	Meta      Meta
+	Generated string
+	Cost      func(money.USD) string
+	Tables    []htmlTable
+	Chart     *htmlChart
+	Reasons   []UnknownReason
+}
+
+type htmlTable struct {
+	Title, Column string
+	Rows          []Row
+}
+
+// htmlChart is a bar chart of known cost per time bucket, in SVG user units.
+type htmlChart struct {
+	Bucket   string
+	Max      string
+	From, To string
+	Bars     []htmlBar
+}
+
+type htmlBar struct {
+	X, Y, W, H float64
+	Title      string
+}
+
+// Chart plot area within the 800x220 SVG view box.
+const (
+	chartLeft, chartRight = 60.0, 790.0
+	chartTop, chartBottom = 10.0, 190.0
+)
+
+// HTML writes s as a single self-contained HTML page: inline CSS and
+// JavaScript, no external resources. It has sortable per-model,
+// per-provider and per-tag tables, a cost-over-time chart when the summary
+// has time buckets, the slowest and most expensive calls, and the reasons
+// for unknown costs.
+func HTML(w io.Writer, s ledger.Summary, m Meta) error {
+	page := htmlPage{
+		S:         s,
+		Meta:      m,
+		Generated: m.generated().Format("2006-01-02 15:04 UTC"),
+		Cost:      func(usd money.USD) string { return formatCost(s, usd) },
+		Tables: []htmlTable{
+			{"Models", "Model", breakdown(s, s.ModelBreakdown)},
+			{"Providers", "Provider", breakdown(s, s.ProviderBreakdown)},
+			{"Tags", "Tag", breakdown(s, s.TagBreakdown)},
+		},
+		Chart:   chart(s),
+		Reasons: unknownReasons(s),
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+// chart lays out the summary's time buckets, or returns nil if it has none.
+// Bars are placed by time, so gaps between buckets show as gaps.
+func chart(s ledger.Summary) *htmlChart {
+	if len(s.Buckets) == 0 || s.BucketSeconds <= 0 {
+		return nil
+	}
+	width := time.Duration(s.BucketSeconds) * time.Second
+	first, last := s.Buckets[0].Start, s.Buckets[len(s.Buckets)-1].Start
+	span := last.Sub(first) + width
+
+	var max money.USD
+	for _, b := range s.Buckets {
+		if b.KnownCostUSD > max {
+			max = b.KnownCostUSD
+		}
+	}
+
+	c := &htmlChart{
+		Bucket: width.String(),
+		Max:    formatCost(s, max),
+		From:   first.Format("2006-01-02 15:04"),
+		To:     last.Add(width).Format("2006-01-02 15:04"),
+	}
+	plotW, plotH := chartRight-chartLeft, chartBottom-chartTop
+	barW := plotW * float64(width) / float64(span)
+	if barW > 2 {
+		barW-- // leave a gap between adjacent bars
+	}
+	for _, b := range s.Buckets {
+		h := 0.0
+		if max > 0 {
+			h = plotH * float64(b.KnownCostUSD) / float64(max)
+		}
+		c.Bars = append(c.Bars, htmlBar{
+			X:     chartLeft + plotW*float64(b.Start.Sub(first))/float64(span),
+			Y:     chartBottom - h,
+			W:     barW,
+			H:     h,
+			Title: fmt.Sprintf("%s: %s, %d calls", b.Start.Format("2006-01-02 15:04"), formatCost(s, b.KnownCostUSD), b.Calls),
+		})
+	}
+	return c
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+// testSummary aggregates a small, varied set of calls.
+func testSummary() ledger.Summary {
+	agg := ledger.NewAggregator()
+	agg.SetBucketWidth(time.Hour)
+	for _, e := range []ledger.Entry{
+		{Timestamp: "2026-10-18T09:10:00Z", Provider: "openai", Model: "gpt-4o-mini", InputTokens: 1000, OutputTokens: 200,
+			CostUSD: money.FromFloat(0.0003), CostKnown: true, DurationMS: 420, TTFBMS: 150, Tags: map[string]string{"team": "search"}},
+		{Timestamp: "2026-10-18T09:20:00Z", Provider: "openai", Model: "gpt-4o", InputTokens: 5000, OutputTokens: 1000,
+			CostUSD: money.FromFloat(0.0225), CostKnown: true, DurationMS: 2100, TTFBMS: 600, Tags: map[string]string{"team": "<ads>"}},
+		{Timestamp: "2026-10-18T11:05:00Z", Provider: "anthropic", Model: "claude-sonnet-4", InputTokens: 3000, OutputTokens: 500,
+			CostUSD: money.FromFloat(0.0165), CostKnown: true, DurationMS: 1800},
+		{Timestamp: "2026-10-18T11:30:00Z", Provider: "anthropic", Model: "claude-x", InputTokens: 10, OutputTokens: 5,
+			UnknownReason: "model not found in pricing table"},
+	} {
+		agg.Add(e)
+	}
+	return agg.Summary()
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	meta := Meta{Version: "0.6.0", PricesAsOf: "2026-10-01", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	if err := HTML(&buf, testSummary(), meta); err != nil {
+		t.Fatalf("HTML failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<h2>Cost over time</h2>",
+		"<h2>Models</h2>", "<h2>Providers</h2>", "<h2>Tags</h2>",
+		"<h2>Slowest calls</h2>", "<h2>Most expensive calls</h2>", "<h2>Unknown costs</h2>",
+		"model not found in pricing table",
+		"team=&lt;ads&gt;",
+		"Prices as of 2026-10-01",
+		"Generated 2026-10-18 12:00 UTC",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+	if strings.Contains(out, "<ads>") {
+		t.Error("tag value is not escaped")
+	}
+	for _, external := range []string{"src=", "href=", "@import", "url("} {
+		if strings.Contains(out, external) {
+			t.Errorf("report references an external resource (%s)", external)
+		}
+	}
+
+	// Models are listed costliest first.
+	models := out[strings.Index(out, "<h2>Models</h2>"):strings.Index(out, "<h2>Providers</h2>")]
+	order := []string{"<td>gpt-4o</td>", "<td>claude-sonnet-4</td>", "<td>gpt-4o-mini</td>", "<td>claude-x</td>"}
+	last := -1
+	for _, m := range order {
+		i := strings.Index(models, m)
+		if i < last {
+			t.Errorf("model %s is out of order", m)
+		}
+		last = i
+	}
+
+	// Two non-empty hourly buckets (09:00 and 11:00), placed by time.
+	if n := strings.Count(out, `<rect class="bar"`); n != 2 {
+		t.Errorf("chart has %d bars, want 2", n)
+	}
+}
+
+func TestChartLayout(t *testing.T) {
+	c := chart(testSummary())
+	if c == nil || len(c.Bars) != 2 {
+		t.Fatalf("chart = %+v, want 2 bars", c)
+	}
+	// Three hours are plotted; the 11:00 bar starts two thirds across and
+	// the costliest bucket (09:00) is full height.
+	third := (chartRight - chartLeft) / 3
+	if got, want := c.Bars[1].X, chartLeft+2*third; got != want {
+		t.Errorf("second bar X = %v, want %v", got, want)
+	}
+	if got, want := c.Bars[0].H, chartBottom-chartTop; got != want {
+		t.Errorf("first bar height = %v, want %v", got, want)
+	}
+	if c.From != "2026-10-18 09:00" || c.To != "2026-10-18 12:00" {
+		t.Errorf("chart spans %s to %s, want 09:00 to 12:00", c.From, c.To)
+	}
+
+	if chart(ledger.Summary{}) != nil {
+		t.Error("chart of a summary without buckets is not nil")
+	}
+}
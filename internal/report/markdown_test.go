@@ -0,0 +1,170 @@
+package report
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"plarix-action/internal/budget"
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var goldenMeta = Meta{Version: "0.6.0", PricesAsOf: "2026-10-01", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+
+// manyModels spreads calls over more models than the main table shows, with
+// ties in cost to check the tie-breaking order.
+func manyModels() ledger.Summary {
+	agg := ledger.NewAggregator()
+	costs := []struct {
+		provider, model string
+		cost            string
+		calls           int
+	}{
+		{"openai", "gpt-4o", "0.5", 3},
+		{"openai", "gpt-4o-mini", "0.01", 10},
+		{"openai", "o3", "1.25", 1},
+		{"anthropic", "claude-opus-4", "2", 2},
+		{"anthropic", "claude-sonnet-4", "0.5", 4},
+		{"anthropic", "claude-haiku-4", "0.5", 4},
+		{"openrouter", "meta-llama/llama-3-70b", "0.002", 5},
+		{"openrouter", "mistralai/mixtral-8x7b", "0.002", 5},
+	}
+	for _, c := range costs {
+		cost, _ := money.Parse(c.cost)
+		for i := 0; i < c.calls; i++ {
+			agg.Add(ledger.Entry{Provider: c.provider, Model: c.model, InputTokens: 100, OutputTokens: 10, CostUSD: cost, CostKnown: true})
+		}
+	}
+	agg.Add(ledger.Entry{Provider: "openai", Model: "gpt-5-preview", UnknownReason: "model not found in pricing table"})
+	agg.Add(ledger.Entry{Provider: "openai", Model: "gpt-4o", UnknownReason: "missing usage"})
+	agg.Add(ledger.Entry{Provider: "openai", Model: "gpt-4o", UnknownReason: "missing usage"})
+	return agg.Summary()
+}
+
+// minuteSeries has one call a minute for the given number of minutes.
+func minuteSeries(minutes int) ledger.Summary {
+	agg := ledger.NewAggregator()
+	agg.SetBucketWidth(time.Minute)
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < minutes; i++ {
+		agg.Add(ledger.Entry{
+			Timestamp: start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339),
+			Provider:  "openai", Model: "gpt-4o-mini",
+			InputTokens: 1000, OutputTokens: 100, CostUSD: money.FromFloat(0.0002), CostKnown: true,
+		})
+	}
+	return agg.Summary()
+}
+
+func TestMarkdownGolden(t *testing.T) {
+	inEUR := manyModels()
+	inEUR.SetCurrency(ledger.Currency{Code: "EUR", PerUSD: 0.92, RatesAsOf: "2026-10-01"})
+	inEUR.Warnings = []string{"Warning: prices are 40 days old"}
+
+	baseline := manyModels()
+	baseline.TotalCalls -= 4
+	baseline.TotalKnownCostUSD -= 2 * money.Scale
+	baseline.ModelBreakdown = map[string]ledger.ModelStats{"o3": {Calls: 1, KnownCostUSD: 5 * money.Scale}}
+	current := manyModels()
+
+	tests := []struct {
+		name string
+		d    Data
+	}{
+		{"empty", Data{Summary: ledger.NewAggregator().Summary()}},
+		{"basic", Data{Summary: testSummary()}},
+		{"many_models", Data{Summary: manyModels()}},
+		{"currency", Data{Summary: inEUR}},
+		{"buckets", Data{Summary: minuteSeries(60)}},
+		{"baseline", Data{
+			Summary: current,
+			Delta:   Compare(current, baseline),
+			Budgets: []budget.Result{
+				{Rule: "total cost", Limit: "$10.0000", Measured: "$10.8700", Message: "cost threshold exceeded: $10.8700 > $10.0000"},
+				{Rule: "model o3", Limit: "$2.0000", Measured: "$1.2500", Passed: true},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Meta = goldenMeta
+			got := render(t, tt.d)
+			path := filepath.Join("testdata", tt.name+".md")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("Render() differs from %s (run go test -update to accept):\n%s", path, diffLines(string(want), got))
+			}
+		})
+	}
+}
+
+// TestMarkdownBucketsFitComment renders a day of minute buckets, which must
+// stay well within GitHub's 65,536-character comment limit.
+func TestMarkdownBucketsFitComment(t *testing.T) {
+	d := Data{Summary: minuteSeries(24 * 60), Meta: goldenMeta}
+	out := render(t, d)
+	if len(out) > 16*1024 {
+		t.Errorf("report is %d bytes for a day of minute buckets", len(out))
+	}
+	if !strings.Contains(out, "(minute buckets, UTC)") || !strings.Contains(out, "2026-10-18 23:59") {
+		t.Errorf("report lacks the latest minute buckets:\n%s", out)
+	}
+	if !strings.Contains(out, "1392 earlier ones") {
+		t.Errorf("report does not mention the omitted buckets:\n%s", out)
+	}
+}
+
+// TestMarkdownDeterministic renders the same summary repeatedly; map
+// iteration order must not leak into the output.
+func TestMarkdownDeterministic(t *testing.T) {
+	s := manyModels()
+	first := render(t, Data{Summary: s, Meta: goldenMeta})
+	for i := 0; i < 20; i++ {
+		if got := render(t, Data{Summary: s, Meta: goldenMeta}); got != first {
+			t.Fatalf("render %d differs:\n%s", i, diffLines(first, got))
+		}
+	}
+}
+
+func render(t *testing.T, d Data) string {
+	t.Helper()
+	out, err := Default.Render(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// diffLines reports the first differing line of two texts.
+func diffLines(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %q\n  got:  %q", i+1, w, g)
+		}
+	}
+	return "(no difference)"
+}
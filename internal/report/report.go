@@ -0,0 +1,94 @@
+// Package report renders a ledger summary for people.
+//
+// Purpose: Turn a ledger.Summary into a markdown report (PR comments, step
+// summaries) from a text/template, or a self-contained HTML page.
+// Public API: Meta, Row, Data, Delta, Template, Default, ParseTemplate,
+// LoadTemplate, Compare, HTML
+// Usage: report.Default.Render(report.Data{Summary: s, Meta: m}), or
+// LoadTemplate(path) for a team's own layout.
+package report
+
+import (
+	"sort"
+	"time"
+
+	"plarix-action/internal/currency"
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+// Meta describes the run a report is rendered for.
+type Meta struct {
+	Version    string    // plarix-scan version
+	PricesAsOf string    // as_of date of the pricing table
+	Generated  time.Time // zero for now
+}
+
+func (m Meta) generated() time.Time {
+	if m.Generated.IsZero() {
+		return time.Now().UTC()
+	}
+	return m.Generated.UTC()
+}
+
+// Row is one line of a breakdown table.
+type Row struct {
+	Name  string
+	Stats ledger.ModelStats
+	Share float64 // fraction of the summary's total known cost
+	Cost  string  // known cost in the summary's display currency
+}
+
+// breakdown returns the rows of a breakdown map, costliest first; ties are
+// ordered by calls, then name, so the order never depends on map iteration.
+func breakdown(s ledger.Summary, m map[string]ledger.ModelStats) []Row {
+	rows := make([]Row, 0, len(m))
+	for name, stats := range m {
+		r := Row{Name: name, Stats: stats, Cost: formatCost(s, stats.KnownCostUSD)}
+		if s.TotalKnownCostUSD > 0 {
+			r.Share = float64(stats.KnownCostUSD) / float64(s.TotalKnownCostUSD)
+		}
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i].Stats, rows[j].Stats
+		if a.KnownCostUSD != b.KnownCostUSD {
+			return a.KnownCostUSD > b.KnownCostUSD
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// formatCost renders a USD amount in the summary's display currency.
+func formatCost(s ledger.Summary, usd money.USD) string {
+	if s.Currency == nil {
+		return "$" + usd.Display()
+	}
+	return currency.Format(s.Currency.Code, s.Currency.FromUSD(usd))
+}
+
+// UnknownReason counts the calls with one unknown-cost reason.
+type UnknownReason struct {
+	Reason string
+	Calls  int
+}
+
+// unknownReasons returns the summary's unknown-cost reasons, most frequent
+// first, then by reason.
+func unknownReasons(s ledger.Summary) []UnknownReason {
+	reasons := make([]UnknownReason, 0, len(s.UnknownReasons))
+	for reason, n := range s.UnknownReasons {
+		reasons = append(reasons, UnknownReason{reason, n})
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Calls != reasons[j].Calls {
+			return reasons[i].Calls > reasons[j].Calls
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+	return reasons
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"plarix-action/internal/budget"
+	"plarix-action/internal/currency"
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+//go:embed markdown.tmpl
+var markdownSource string
+
+// Default is the markdown report used for PR comments and step summaries.
+// Every table is sorted by known cost, descending, so the output depends
+// only on the data.
+var Default = mustParse("default", markdownSource)
+
+// Data is what a report template renders. Besides the fields, templates can
+// call the methods below (e.g. {{.Cost .Summary.TotalKnownCostUSD}}) and
+// these functions:
+//
+//	pct f             a fraction as a percentage, "36.8%"
+//	signedPct f       the same with a sign, "+12.5%"
+//	head n rows       the first n rows of a table
+//	table name rows   the argument of the "table" template, which renders a
+//	                  breakdown table with a first column called name
+//
+// A custom template can also include the whole default report with
+// {{template "default" .}}.
+type Data struct {
+	Summary ledger.Summary
+	Meta    Meta
+	Delta   *Delta          // change from a baseline run; nil without one
+	Budgets []budget.Result // budget rule outcomes; empty without rules
+}
+
+// Cost formats a USD amount in the summary's display currency.
+func (d Data) Cost(usd money.USD) string {
+	return formatCost(d.Summary, usd)
+}
+
+// Signed formats a USD change in the display currency, with its sign.
+func (d Data) Signed(usd money.USD) string {
+	if usd < 0 {
+		return "-" + d.Cost(usd.Abs())
+	}
+	return "+" + d.Cost(usd)
+}
+
+// TotalCost formats the total known cost, followed by the USD amount when
+// the summary is in another currency.
+func (d Data) TotalCost() string {
+	s := d.Summary
+	if s.Currency != nil {
+		return fmt.Sprintf("%s ($%s USD)", currency.Format(s.Currency.Code, s.TotalKnownCost), s.TotalKnownCostUSD.Display())
+	}
+	return fmt.Sprintf("$%s USD", s.TotalKnownCostUSD.Display())
+}
+
+// Models returns the per-model rows, costliest first.
+func (d Data) Models() []Row { return breakdown(d.Summary, d.Summary.ModelBreakdown) }
+
+// Providers returns the per-provider rows, costliest first.
+func (d Data) Providers() []Row { return breakdown(d.Summary, d.Summary.ProviderBreakdown) }
+
+// Tags returns the per-tag rows, costliest first.
+func (d Data) Tags() []Row { return breakdown(d.Summary, d.Summary.TagBreakdown) }
+
+// UnknownReasons returns the reasons for unknown costs, most frequent first.
+func (d Data) UnknownReasons() []UnknownReason { return unknownReasons(d.Summary) }
+
+// maxBucketRows caps the time series in the markdown report, which must fit
+// in a PR comment; the summary JSON and HTML report keep every bucket.
+const maxBucketRows = 48
+
+// BucketWidth names the width of the summary's time buckets as it is given
+// on the command line: "minute", "hour", "day", or a duration such as "15m".
+func (d Data) BucketWidth() string {
+	width := time.Duration(d.Summary.BucketSeconds) * time.Second
+	switch width {
+	case time.Minute:
+		return "minute"
+	case time.Hour:
+		return "hour"
+	case 24 * time.Hour:
+		return "day"
+	}
+	s := width.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// RecentBuckets returns the latest time buckets, at most as many as the
+// markdown report shows.
+func (d Data) RecentBuckets() []ledger.Bucket {
+	b := d.Summary.Buckets
+	if len(b) > maxBucketRows {
+		return b[len(b)-maxBucketRows:]
+	}
+	return b
+}
+
+// OlderBuckets is the number of time buckets RecentBuckets leaves out.
+func (d Data) OlderBuckets() int {
+	return len(d.Summary.Buckets) - len(d.RecentBuckets())
+}
+
+// Generated is the report time, e.g. "2026-10-18 12:00 UTC".
+func (d Data) Generated() string {
+	return d.Meta.generated().Format("2006-01-02 15:04 UTC")
+}
+
+// Template is a parsed report template.
+type Template struct {
+	t *template.Template
+}
+
+// mdTable is the argument of the "table" template.
+type mdTable struct {
+	Column string
+	Rule   string
+	Rows   []Row
+}
+
+var funcs = template.FuncMap{
+	"pct":       func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"signedPct": func(f float64) string { return fmt.Sprintf("%+.1f%%", f*100) },
+	"head": func(n int, rows []Row) []Row {
+		if len(rows) > n {
+			return rows[:n]
+		}
+		return rows
+	},
+	"table": func(column string, rows []Row) mdTable {
+		return mdTable{Column: column, Rule: strings.Repeat("-", len(column)+2), Rows: rows}
+	},
+}
+
+func mustParse(name, text string) *Template {
+	t, err := parse(template.New(name).Funcs(funcs), text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func parse(t *template.Template, text string) (*Template, error) {
+	if _, err := t.Parse(text); err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+// ParseTemplate parses a report template. It is parsed alongside the
+// default template, whose "default" and "table" templates it may use.
+func ParseTemplate(name, text string) (*Template, error) {
+	base, err := Default.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	t, err := parse(base.New(name), text)
+	if err != nil {
+		return nil, fmt.Errorf("parse report template: %w", err)
+	}
+	return t, nil
+}
+
+// LoadTemplate reads and parses a report template file.
+func LoadTemplate(path string) (*Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read report template: %w", err)
+	}
+	return ParseTemplate(filepath.Base(path), string(text))
+}
+
+// Render executes the template with d.
+func (t *Template) Render(d Data) (string, error) {
+	var b strings.Builder
+	if err := t.t.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("render report: %w", err)
+	}
+	return b.String(), nil
+}
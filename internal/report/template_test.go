@@ -0,0 +1,134 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"plarix-action/internal/ledger"
+	"plarix-action/internal/money"
+)
+
+func TestParseTemplate(t *testing.T) {
+	d := Data{Summary: manyModels(), Meta: goldenMeta}
+	d.Delta = Compare(d.Summary, ledger.Summary{TotalCalls: 30, TotalKnownCostUSD: 10 * money.Scale})
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"fields", "{{.Summary.TotalCalls}} calls, {{.TotalCost}}", "37 calls, $10.8700 USD"},
+		{"delta", "{{with .Delta}}{{$.Signed .CostUSD}} ({{signedPct .Change}}){{end}}", "+$0.8700 (+8.7%)"},
+		{"rows", "{{range head 2 .Models}}{{.Name}}={{.Cost}} {{end}}", "claude-opus-4=$4.0000 claude-haiku-4=$2.0000 "},
+		{"table", `{{template "table" table "Provider" (head 1 .Providers)}}`,
+			"| Provider | Calls | Tokens (in/out) | Known Cost | Share |\n|----------|-------|-----------------|------------|-------|\n| anthropic | 10 | 1000 / 100 | $8.0000 | 73.6% |\n\n"},
+		{"no budgets", "{{range .Budgets}}x{{else}}none{{end}}", "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate("custom", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.Render(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTemplateIncludesDefault(t *testing.T) {
+	d := Data{Summary: testSummary(), Meta: goldenMeta}
+	tmpl, err := ParseTemplate("custom", "{{template \"default\" .}}\nOwned by the platform team.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := render(t, d) + "\nOwned by the platform team.\n"; got != want {
+		t.Errorf("Render() differs:\n%s", diffLines(want, got))
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := ParseTemplate("bad", "{{.Summary.TotalCalls"); err == nil {
+		t.Error("ParseTemplate(unclosed action) succeeded, want error")
+	}
+	if _, err := LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("LoadTemplate(missing file) succeeded, want error")
+	}
+
+	tmpl, err := ParseTemplate("field", "{{.Summary.NoSuchField}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(Data{}); err == nil || !strings.Contains(err.Error(), "NoSuchField") {
+		t.Errorf("Render(unknown field) error = %v, want it to name the field", err)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comment.tmpl")
+	if err := os.WriteFile(path, []byte("Spent {{.TotalCost}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render(Data{Summary: testSummary()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Spent $" + testSummary().TotalKnownCostUSD.Display() + " USD"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := ledger.Summary{
+		TotalCalls:        4,
+		TotalKnownCostUSD: 4 * money.Scale,
+		ModelBreakdown: map[string]ledger.ModelStats{
+			"gpt-4o":      {Calls: 2, KnownCostUSD: 3 * money.Scale},
+			"gpt-4o-mini": {Calls: 1, KnownCostUSD: money.Scale},
+			"o3":          {Calls: 1},
+		},
+	}
+	current := ledger.Summary{
+		TotalCalls:        3,
+		TotalKnownCostUSD: 5 * money.Scale,
+		ModelBreakdown: map[string]ledger.ModelStats{
+			"gpt-4o":      {Calls: 2, KnownCostUSD: 3 * money.Scale},
+			"gpt-4o-mini": {Calls: 0},
+			"claude":      {Calls: 1, KnownCostUSD: 2 * money.Scale},
+		},
+	}
+
+	d := Compare(current, baseline)
+	if d.CostUSD != money.Scale || d.Calls != -1 || d.Change != 0.25 {
+		t.Errorf("Compare() = %s, %d calls, %v; want 1, -1 calls, 0.25", d.CostUSD, d.Calls, d.Change)
+	}
+	var names []string
+	for _, m := range d.Models {
+		names = append(names, m.Name)
+	}
+	if got, want := strings.Join(names, ","), "claude,gpt-4o-mini,o3"; got != want {
+		t.Errorf("changed models = %s, want %s", got, want)
+	}
+	if m := d.Models[1]; m.Change != -money.Scale || m.Calls != -1 {
+		t.Errorf("gpt-4o-mini change = %s, %d calls; want -1, -1 calls", m.Change, m.Calls)
+	}
+
+	if d := Compare(current, ledger.Summary{}); d.Change != 0 {
+		t.Errorf("Change with a zero baseline = %v, want 0", d.Change)
+	}
+}
@@ -0,0 +1,13 @@
+// Package prices bundles the default pricing table into the binary.
+//
+// Purpose: Make prices.json available without a file on disk.
+// Public API: JSON
+// Usage: pricing.Default parses JSON; prices.json stays the file to edit.
+package prices
+
+import _ "embed"
+
+// JSON is the bundled pricing table, as shipped in prices/prices.json.
+//
+//go:embed prices.json
+var JSON []byte